@@ -0,0 +1,218 @@
+package tfo
+
+import (
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBlackholeInitialBackoff = 5 * time.Minute
+	defaultBlackholeMaxBackoff     = 24 * time.Hour
+	defaultBlackholeAttemptTimeout = 2 * time.Second
+
+	// blackholeMinSweepLen is the minimum number of entries before stale entries are swept.
+	blackholeMinSweepLen = 64
+)
+
+// BlackholeCache remembers destinations where TCP Fast Open appears to be blackholed,
+// for example by middleboxes that drop SYNs carrying data or TFO options.
+//
+// When [Dialer.BlackholeCache] is set, each TFO connection attempt is bounded by
+// [BlackholeCache.AttemptTimeout]. If the attempt times out or is reset, the destination is retried
+// without TFO, and if the retry succeeds, the destination is reported as a blackhole.
+// Blackholed destinations are dialed without TFO until their backoff expires.
+// Each consecutive failure doubles the backoff, starting from [BlackholeCache.InitialBackoff],
+// up to [BlackholeCache.MaxBackoff]. A successful TFO handshake clears the destination.
+//
+// On Linux, where the dial call may return before the handshake completes,
+// the dialer also waits for the handshake, and treats a SYN retransmission
+// without acknowledged SYN data as a failure. Other errors, such as unreachable hosts,
+// are not specific to TFO, and are returned without a retry.
+//
+// An entry is remembered for MaxBackoff after its backoff expires, so that consecutive
+// failures can be detected. Older entries are evicted.
+//
+// The zero value is ready for use. A BlackholeCache is safe for concurrent use,
+// and may be shared by multiple dialers. Its fields must not be modified after first use.
+type BlackholeCache struct {
+	// IPv4PrefixLen is the length of the prefix that an IPv4 blackhole entry covers.
+	// If zero, each entry covers a single address.
+	IPv4PrefixLen int
+
+	// IPv6PrefixLen is the length of the prefix that an IPv6 blackhole entry covers.
+	// If zero, each entry covers a single address.
+	IPv6PrefixLen int
+
+	// InitialBackoff is how long a destination is blackholed after its first failure.
+	// If zero, the default of 5 minutes is used.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum time a destination is blackholed for.
+	// If zero, the default of 24 hours is used.
+	MaxBackoff time.Duration
+
+	// AttemptTimeout is the maximum time a TFO connection attempt may take
+	// before it is abandoned and retried without TFO.
+	// If zero, the default of 2 seconds is used.
+	AttemptTimeout time.Duration
+
+	mu       sync.Mutex
+	entries  map[netip.Prefix]BlackholeEntry
+	sweepLen int
+}
+
+// BlackholeEntry is a destination in a [BlackholeCache].
+type BlackholeEntry struct {
+	// Prefix is the blackholed destination.
+	Prefix netip.Prefix
+
+	// Failures is the number of consecutive TFO failures.
+	Failures int
+
+	// LastFailure is the time of the last TFO failure.
+	LastFailure time.Time
+
+	// Until is the time when TFO will be attempted again.
+	Until time.Time
+}
+
+func (c *BlackholeCache) initialBackoff() time.Duration {
+	if c.InitialBackoff > 0 {
+		return c.InitialBackoff
+	}
+	return defaultBlackholeInitialBackoff
+}
+
+func (c *BlackholeCache) maxBackoff() time.Duration {
+	if c.MaxBackoff > 0 {
+		return c.MaxBackoff
+	}
+	return defaultBlackholeMaxBackoff
+}
+
+func (c *BlackholeCache) attemptTimeout() time.Duration {
+	if c.AttemptTimeout > 0 {
+		return c.AttemptTimeout
+	}
+	return defaultBlackholeAttemptTimeout
+}
+
+// prefix returns the cache key for addr.
+func (c *BlackholeCache) prefix(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap().WithZone("")
+	bits := c.IPv6PrefixLen
+	if addr.Is4() {
+		bits = c.IPv4PrefixLen
+	}
+	if bits <= 0 || bits > addr.BitLen() {
+		bits = addr.BitLen()
+	}
+	p, _ := addr.Prefix(bits)
+	return p
+}
+
+// stale returns whether e is too old to be relevant at now.
+func (c *BlackholeCache) stale(e BlackholeEntry, now time.Time) bool {
+	return now.Sub(e.Until) > c.maxBackoff()
+}
+
+// Blocked returns whether TFO should not be used for addr.
+func (c *BlackholeCache) Blocked(addr netip.Addr) bool {
+	p := c.prefix(addr)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[p]
+	if !ok {
+		return false
+	}
+	if c.stale(e, now) {
+		delete(c.entries, p)
+		return false
+	}
+	return now.Before(e.Until)
+}
+
+// ReportFailure records a TFO failure for addr, and blackholes its destination
+// for an exponentially increasing duration.
+func (c *BlackholeCache) ReportFailure(addr netip.Addr) {
+	p := c.prefix(addr)
+	now := time.Now()
+	maxBackoff := c.maxBackoff()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[p]
+	// Forget failures that are too old to be relevant.
+	if !ok || c.stale(e, now) {
+		e = BlackholeEntry{Prefix: p}
+	}
+	e.Failures++
+	e.LastFailure = now
+
+	backoff := c.initialBackoff()
+	for i := 1; i < e.Failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	e.Until = now.Add(min(backoff, maxBackoff))
+
+	if c.entries == nil {
+		c.entries = make(map[netip.Prefix]BlackholeEntry)
+	}
+	c.entries[p] = e
+
+	// Sweep stale entries whenever the map doubles in size since the last sweep,
+	// so that the cost is amortized over insertions.
+	if len(c.entries) >= c.sweepLen {
+		for k, v := range c.entries {
+			if c.stale(v, now) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweepLen = max(2*len(c.entries), blackholeMinSweepLen)
+	}
+}
+
+// ReportSuccess records a successful TFO handshake with addr, and clears its destination.
+func (c *BlackholeCache) ReportSuccess(addr netip.Addr) {
+	c.Remove(addr)
+}
+
+// Remove removes the entry that covers addr, if any.
+func (c *BlackholeCache) Remove(addr netip.Addr) {
+	p := c.prefix(addr)
+	c.mu.Lock()
+	delete(c.entries, p)
+	c.mu.Unlock()
+}
+
+// Clear removes all entries.
+func (c *BlackholeCache) Clear() {
+	c.mu.Lock()
+	clear(c.entries)
+	c.mu.Unlock()
+}
+
+// Entries returns a snapshot of all entries, including expired ones that have not been evicted,
+// sorted by prefix.
+func (c *BlackholeCache) Entries() []BlackholeEntry {
+	c.mu.Lock()
+	entries := make([]BlackholeEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	c.mu.Unlock()
+
+	slices.SortFunc(entries, func(a, b BlackholeEntry) int {
+		if c := a.Prefix.Addr().Compare(b.Prefix.Addr()); c != 0 {
+			return c
+		}
+		return a.Prefix.Bits() - b.Prefix.Bits()
+	})
+	return entries
+}
@@ -0,0 +1,67 @@
+package tfo
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// waitHandshake waits for the handshake of c to complete.
+//
+// When the kernel has a TFO cookie for the server, sendto(MSG_FASTOPEN) returns
+// as soon as the SYN is sent, so the dial call does not observe the handshake.
+func waitHandshake(ctx context.Context, c *net.TCPConn) error {
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return connWriteFunc(ctx, c, func(c *net.TCPConn) (err error) {
+		if perr := rawConn.Write(func(fd uintptr) bool {
+			var info *unix.TCPInfo
+			info, err = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+			if err != nil {
+				err = os.NewSyscallError("getsockopt(TCP_INFO)", err)
+				return true
+			}
+			if info.State == unix.BPF_TCP_SYN_SENT {
+				// Wait for the socket to become writable, or for an error.
+				return false
+			}
+			err = getSocketError(int(fd), connectSyscallName)
+			return true
+		}); perr != nil {
+			return perr
+		}
+		return err
+	})
+}
+
+// tfoSYNRetransmitted is the tcpi_fastopen_client_fail value (TFO_SYN_RETRANSMITTED)
+// that indicates the SYN with data was not acknowledged, and the SYN was retransmitted.
+const tfoSYNRetransmitted = 3
+
+// tcpiFastopenClientFail returns tcpi_fastopen_client_fail (Linux 5.5+), which is not exposed by [unix.TCPInfo].
+// It is a 2-bit field in the byte after tcpi_options, following the 1-bit tcpi_delivery_rate_app_limited.
+func tcpiFastopenClientFail(info *unix.TCPInfo) uint8 {
+	b := (*[8]byte)(unsafe.Pointer(info))[7]
+	if isBigEndian {
+		// Bit-fields are allocated from the most significant bit.
+		return b >> 5 & 3
+	}
+	return b >> 1 & 3
+}
+
+var isBigEndian = binary.NativeEndian.Uint16([]byte{0, 1}) == 1
+
+// synDataLost returns whether the SYN with data sent by c was lost, and retransmitted without data.
+func synDataLost(c *net.TCPConn) bool {
+	info, err := getTCPInfo(c)
+	if err != nil {
+		return false
+	}
+	return tcpiFastopenClientFail(info) == tfoSYNRetransmitted
+}
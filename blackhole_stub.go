@@ -0,0 +1,17 @@
+//go:build !linux
+
+package tfo
+
+import (
+	"context"
+	"net"
+)
+
+// waitHandshake is a no-op. Only Linux is supported.
+func waitHandshake(_ context.Context, _ *net.TCPConn) error {
+	return nil
+}
+
+func synDataLost(_ *net.TCPConn) bool {
+	return false
+}
@@ -0,0 +1,195 @@
+package tfo
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBlackholeCache(t *testing.T) {
+	c := BlackholeCache{
+		IPv4PrefixLen:  24,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     3 * time.Minute,
+	}
+
+	addr4a := netip.MustParseAddr("192.0.2.1")
+	addr4b := netip.MustParseAddr("::ffff:192.0.2.2")
+	addr6a := netip.MustParseAddr("2001:db8::1")
+	addr6b := netip.MustParseAddr("2001:db8::2")
+
+	if c.Blocked(addr4a) {
+		t.Error("empty cache blocks", addr4a)
+	}
+
+	// Backoff doubles on each failure, up to MaxBackoff.
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		c.ReportFailure(addr4a)
+		entries := c.Entries()
+		if len(entries) != 1 {
+			t.Fatalf("len(Entries()) = %d, want 1", len(entries))
+		}
+		if got := entries[0].Until.Sub(entries[0].LastFailure); got != want {
+			t.Errorf("backoff = %v, want %v", got, want)
+		}
+	}
+
+	// IPv4 entries cover the configured prefix, and IPv6 entries cover a single address.
+	if !c.Blocked(addr4b) {
+		t.Error("Blocked(", addr4b, ") = false, want true")
+	}
+	c.ReportFailure(addr6a)
+	if !c.Blocked(addr6a) {
+		t.Error("Blocked(", addr6a, ") = false, want true")
+	}
+	if c.Blocked(addr6b) {
+		t.Error("Blocked(", addr6b, ") = true, want false")
+	}
+
+	entries := c.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("len(Entries()) = %d, want 2", len(entries))
+	}
+	if want := netip.MustParsePrefix("192.0.2.0/24"); entries[0].Prefix != want {
+		t.Errorf("entries[0].Prefix = %v, want %v", entries[0].Prefix, want)
+	}
+	if want := netip.PrefixFrom(addr6a, 128); entries[1].Prefix != want {
+		t.Errorf("entries[1].Prefix = %v, want %v", entries[1].Prefix, want)
+	}
+	if entries[0].Failures != 4 {
+		t.Errorf("entries[0].Failures = %d, want 4", entries[0].Failures)
+	}
+
+	c.ReportSuccess(addr4b)
+	if c.Blocked(addr4a) {
+		t.Error("Blocked(", addr4a, ") = true after ReportSuccess, want false")
+	}
+
+	c.Clear()
+	if n := len(c.Entries()); n != 0 {
+		t.Errorf("len(Entries()) = %d after Clear, want 0", n)
+	}
+}
+
+func TestBlackholeCacheEviction(t *testing.T) {
+	c := BlackholeCache{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}
+
+	addr := netip.MustParseAddr("2001:db8::1")
+	c.ReportFailure(addr)
+	time.Sleep(5 * time.Millisecond)
+
+	// Lookups evict stale entries.
+	if c.Blocked(addr) {
+		t.Error("Blocked(", addr, ") = true, want false")
+	}
+	if n := len(c.Entries()); n != 0 {
+		t.Errorf("len(Entries()) = %d after lookup, want 0", n)
+	}
+
+	// Insertions sweep stale entries once the map grows.
+	base := addr.As16()
+	for i := range blackholeMinSweepLen {
+		base[15] = byte(i)
+		c.ReportFailure(netip.AddrFrom16(base))
+	}
+	time.Sleep(5 * time.Millisecond)
+	for i := range blackholeMinSweepLen {
+		base[14] = 1
+		base[15] = byte(i)
+		c.ReportFailure(netip.AddrFrom16(base))
+	}
+	if n := len(c.Entries()); n > blackholeMinSweepLen {
+		t.Errorf("len(Entries()) = %d, want <= %d", n, blackholeMinSweepLen)
+	}
+}
+
+func testDialBlackholeCache(t *testing.T, lc ListenConfig, d Dialer) {
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	raddr := ln.Addr().(*net.TCPAddr).AddrPort()
+
+	go func() {
+		for range 2 {
+			conn, err := ln.Accept()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			readUntilEOF(conn, hello, t)
+			conn.Close()
+		}
+	}()
+
+	var cache BlackholeCache
+	d.BlackholeCache = &cache
+
+	dial := func() TFOInfo {
+		c, err := d.DialContext(t.Context(), "tcp", raddr.String(), hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		info, _ := ConnTFOInfo(c)
+		c.(*net.TCPConn).CloseWrite()
+		readUntilEOF(c, nil, t)
+		return info
+	}
+
+	// A working destination must not be blackholed.
+	info := dial()
+	t.Logf("info: %+v", info)
+	if cache.Blocked(raddr.Addr()) {
+		t.Error("working destination is blackholed")
+	}
+
+	// A blackholed destination must be dialed without TFO.
+	cache.ReportFailure(raddr.Addr())
+	if info = dial(); info.Path != DialPathPlain {
+		t.Errorf("info.Path = %v for blackholed destination, want %v", info.Path, DialPathPlain)
+	}
+}
+
+// TestDialBlackholeCache ensures that [Dialer.BlackholeCache] is consulted and not polluted by working destinations.
+func TestDialBlackholeCache(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testDialBlackholeCache)
+	}
+}
+
+// TestDialBlackholeCacheNoRetry ensures that errors other than timeouts and resets are not retried without TFO.
+func TestDialBlackholeCacheNoRetry(t *testing.T) {
+	if comptimeDialNoTFO {
+		t.Skip("TFO is not supported on the current platform")
+	}
+
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raddr := ln.Addr().String()
+	ln.Close()
+
+	var cache BlackholeCache
+	d := Dialer{Fallback: true, BlackholeCache: &cache, SupportCache: new(SupportCache)}
+
+	var r traceRecorder
+	c, err := d.DialContext(WithDialTrace(t.Context(), r.trace()), "tcp", raddr, hello)
+	if err == nil {
+		c.Close()
+		t.Fatal("dial to a closed port succeeded")
+	}
+	if slices.Contains(r.events, "Fallback "+FallbackReasonBlackholeRetry.String()) {
+		t.Errorf("events = %q, want no blackhole retry", r.events)
+	}
+	if len(cache.Entries()) != 0 {
+		t.Errorf("Entries() = %v, want none", cache.Entries())
+	}
+}
@@ -0,0 +1,40 @@
+package tfo
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// withDeferAccept returns a copy of lc, whose Control function also sets TCP_DEFER_ACCEPT,
+// and whose DeferAccept is cleared.
+func (lc *ListenConfig) withDeferAccept() *ListenConfig {
+	// Copy these values to avoid referencing lc in llc.Control.
+	ctrlFn := lc.Control
+	secs := deferAcceptSeconds(lc.DeferAccept)
+	fallback := lc.Fallback
+	llc := *lc
+	llc.DeferAccept = 0
+	llc.Control = func(network, address string, c syscall.RawConn) (err error) {
+		if ctrlFn != nil {
+			if err = ctrlFn(network, address, c); err != nil {
+				return err
+			}
+		}
+
+		if cerr := c.Control(func(fd uintptr) {
+			err = setDeferAccept(fd, secs)
+		}); cerr != nil {
+			return cerr
+		}
+
+		if err != nil {
+			if fallback && errors.Is(err, errors.ErrUnsupported) {
+				return nil
+			}
+			return os.NewSyscallError("setsockopt(TCP_DEFER_ACCEPT)", err)
+		}
+		return nil
+	}
+	return &llc
+}
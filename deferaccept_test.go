@@ -0,0 +1,118 @@
+package tfo
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDeferAcceptSeconds(t *testing.T) {
+	for _, c := range []struct {
+		timeout time.Duration
+		want    int
+	}{
+		{-time.Second, 0},
+		{0, 0},
+		{time.Nanosecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+	} {
+		if got := deferAcceptSeconds(c.timeout); got != c.want {
+			t.Errorf("deferAcceptSeconds(%v) = %d, want %d", c.timeout, got, c.want)
+		}
+	}
+}
+
+func TestListenDeferAccept(t *testing.T) {
+	lc := ListenConfig{DeferAccept: 5 * time.Second}
+
+	if runtime.GOOS != "linux" {
+		if ln, err := lc.Listen(t.Context(), "tcp", "[::1]:"); !errors.Is(err, errors.ErrUnsupported) {
+			if err == nil {
+				ln.Close()
+			}
+			t.Errorf("Listen error = %v, want %v", err, errors.ErrUnsupported)
+		}
+		lc.Fallback = true
+	}
+
+	ln, err := lc.ListenTCP(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	const delay = 100 * time.Millisecond
+	go func() {
+		time.Sleep(delay)
+		c.Write(hello)
+	}()
+
+	start := time.Now()
+	sc, n, _, err := ln.AcceptPeek(make([]byte, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	if runtime.GOOS == "linux" {
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("Accept returned after %v, before data arrived", elapsed)
+		}
+		if n != len(hello) {
+			t.Errorf("n = %d, want %d", n, len(hello))
+		}
+	}
+
+	c.(*net.TCPConn).CloseWrite()
+	readUntilEOF(sc, hello, t)
+}
+
+func testListenerAcceptPeek(t *testing.T, lc ListenConfig, d Dialer) {
+	ln, err := lc.ListenTCP(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c, err := d.DialContext(t.Context(), "tcp", ln.Addr().String(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	b := make([]byte, 64)
+	sc, n, info, err := ln.AcceptPeek(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	t.Logf("n: %d, info: %+v", n, info)
+
+	if !bytes.HasPrefix(hello, b[:n]) {
+		t.Errorf("peeked %q, want prefix of %q", b[:n], hello)
+	}
+	if runtime.GOOS == "linux" && info.SYNData && n != len(hello) {
+		t.Errorf("n = %d with SYN data, want %d", n, len(hello))
+	}
+
+	// Peeked data must still be readable.
+	c.(*net.TCPConn).CloseWrite()
+	readUntilEOF(sc, hello, t)
+}
+
+// TestListenerAcceptPeek ensures that [TCPListener.AcceptPeek] does not consume data.
+func TestListenerAcceptPeek(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testListenerAcceptPeek)
+	}
+}
@@ -0,0 +1,210 @@
+package tfo
+
+import (
+	"context"
+	"net/netip"
+)
+
+// FallbackReason identifies why a dial call deviated from its preferred TFO code path.
+type FallbackReason uint8
+
+const (
+	// FallbackReasonCachedNoTFO means lack of TFO support was recorded in the [SupportCache]
+	// by an earlier dial call, and the connection was dialed without TFO.
+	FallbackReasonCachedNoTFO FallbackReason = iota + 1
+
+	// FallbackReasonCachedSendto means lack of TCP_FASTOPEN_CONNECT support was recorded
+	// in the [SupportCache] by an earlier dial call, and the connection was dialed with
+	// sendto(MSG_FASTOPEN) (Linux).
+	FallbackReasonCachedSendto
+
+	// FallbackReasonSendto means TCP_FASTOPEN_CONNECT is not supported, and the connection
+	// is retried with sendto(MSG_FASTOPEN) (Linux).
+	FallbackReasonSendto
+
+	// FallbackReasonUnsupported means TFO is not supported by the system,
+	// and the connection proceeds without TFO.
+	FallbackReasonUnsupported
+
+	// FallbackReasonBlackholed means the destination is blackholed in the [BlackholeCache],
+	// and the connection was dialed without TFO.
+	FallbackReasonBlackholed
+
+	// FallbackReasonBlackholeRetry means a TFO connection attempt was reset or timed out,
+	// and the destination is retried without TFO.
+	FallbackReasonBlackholeRetry
+
+	// FallbackReasonMultipathTCP means a Multipath TCP socket could not be used,
+	// and the connection is retried with plain TCP.
+	FallbackReasonMultipathTCP
+)
+
+// String implements [fmt.Stringer].
+func (r FallbackReason) String() string {
+	switch r {
+	case FallbackReasonCachedNoTFO:
+		return "cached no TFO"
+	case FallbackReasonCachedSendto:
+		return "cached sendto"
+	case FallbackReasonSendto:
+		return "sendto"
+	case FallbackReasonUnsupported:
+		return "unsupported"
+	case FallbackReasonBlackholed:
+		return "blackholed"
+	case FallbackReasonBlackholeRetry:
+		return "blackhole retry"
+	case FallbackReasonMultipathTCP:
+		return "multipath TCP"
+	default:
+		return "unknown"
+	}
+}
+
+// DialTrace is a set of hooks to run at various stages of a [Dialer] dial call.
+// Any particular hook may be nil. Functions may be called concurrently from different goroutines,
+// as connection attempts to multiple addresses are raced, and some may be called after
+// the dial call has returned.
+//
+// Name resolution and connection attempts are reported on all TFO paths. Like [net/http/httptrace],
+// IP literals are not looked up, and not reported. [Dialer.DialTCP] does not resolve names
+// or race addresses, and reports neither.
+type DialTrace struct {
+	// DNSStart is called when a name lookup begins.
+	DNSStart func(host string)
+
+	// DNSDone is called when a name lookup ends.
+	DNSDone func(addrs []netip.Addr, err error)
+
+	// ConnectStart is called when a connection attempt to raddr begins.
+	ConnectStart func(raddr netip.AddrPort)
+
+	// ConnectDone is called when a connection attempt to raddr ends.
+	// Attempts that lost the race are reported with a cancellation error.
+	ConnectDone func(raddr netip.AddrPort, err error)
+
+	// RaceWon is called with the address of the connection that won the race
+	// among connection attempts, such as between IPv6 and IPv4 addresses.
+	RaceWon func(raddr netip.AddrPort)
+
+	// SockoptError is called when setting a socket option fails,
+	// before the error is either returned, or ignored when falling back.
+	SockoptError func(name string, err error)
+
+	// Fallback is called when the dial call deviates from its preferred TFO code path.
+	// err is the error that caused the fallback, if any.
+	Fallback func(reason FallbackReason, err error)
+
+	// PayloadSent is called when the dial call returns a connection that has sent its initial payload,
+	// with how the payload was sent, and the number of bytes carried in the SYN.
+	// The SYNDataAcked and MultipathTCP fields of info are not populated.
+	PayloadSent func(info TFOInfo)
+}
+
+type dialTraceContextKey struct{}
+
+// WithDialTrace returns a new context based on ctx, whose dial calls run the hooks in trace.
+// If ctx already has a trace, both traces are called, with the hooks in trace called first.
+func WithDialTrace(ctx context.Context, trace *DialTrace) context.Context {
+	if trace == nil {
+		panic("nil trace")
+	}
+	if old := ContextDialTrace(ctx); old != nil {
+		trace = trace.compose(old)
+	}
+	return context.WithValue(ctx, dialTraceContextKey{}, trace)
+}
+
+// ContextDialTrace returns the [DialTrace] associated with ctx, or nil if there is none.
+func ContextDialTrace(ctx context.Context) *DialTrace {
+	trace, _ := ctx.Value(dialTraceContextKey{}).(*DialTrace)
+	return trace
+}
+
+// compose returns a new trace that calls the hooks in t, then the hooks in old.
+func (t *DialTrace) compose(old *DialTrace) *DialTrace {
+	return &DialTrace{
+		DNSStart: func(host string) {
+			t.dnsStart(host)
+			old.dnsStart(host)
+		},
+		DNSDone: func(addrs []netip.Addr, err error) {
+			t.dnsDone(addrs, err)
+			old.dnsDone(addrs, err)
+		},
+		ConnectStart: func(raddr netip.AddrPort) {
+			t.connectStart(raddr)
+			old.connectStart(raddr)
+		},
+		ConnectDone: func(raddr netip.AddrPort, err error) {
+			t.connectDone(raddr, err)
+			old.connectDone(raddr, err)
+		},
+		RaceWon: func(raddr netip.AddrPort) {
+			t.raceWon(raddr)
+			old.raceWon(raddr)
+		},
+		SockoptError: func(name string, err error) {
+			t.sockoptError(name, err)
+			old.sockoptError(name, err)
+		},
+		Fallback: func(reason FallbackReason, err error) {
+			t.fallback(reason, err)
+			old.fallback(reason, err)
+		},
+		PayloadSent: func(info TFOInfo) {
+			t.payloadSent(info)
+			old.payloadSent(info)
+		},
+	}
+}
+
+// The following methods call the corresponding hooks, if t and the hooks are not nil.
+
+func (t *DialTrace) dnsStart(host string) {
+	if t != nil && t.DNSStart != nil {
+		t.DNSStart(host)
+	}
+}
+
+func (t *DialTrace) dnsDone(addrs []netip.Addr, err error) {
+	if t != nil && t.DNSDone != nil {
+		t.DNSDone(addrs, err)
+	}
+}
+
+func (t *DialTrace) connectStart(raddr netip.AddrPort) {
+	if t != nil && t.ConnectStart != nil {
+		t.ConnectStart(raddr)
+	}
+}
+
+func (t *DialTrace) connectDone(raddr netip.AddrPort, err error) {
+	if t != nil && t.ConnectDone != nil {
+		t.ConnectDone(raddr, err)
+	}
+}
+
+func (t *DialTrace) raceWon(raddr netip.AddrPort) {
+	if t != nil && t.RaceWon != nil {
+		t.RaceWon(raddr)
+	}
+}
+
+func (t *DialTrace) sockoptError(name string, err error) {
+	if t != nil && t.SockoptError != nil {
+		t.SockoptError(name, err)
+	}
+}
+
+func (t *DialTrace) fallback(reason FallbackReason, err error) {
+	if t != nil && t.Fallback != nil {
+		t.Fallback(reason, err)
+	}
+}
+
+func (t *DialTrace) payloadSent(info TFOInfo) {
+	if t != nil && t.PayloadSent != nil {
+		t.PayloadSent(info)
+	}
+}
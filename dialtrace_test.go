@@ -0,0 +1,176 @@
+package tfo
+
+import (
+	"net"
+	"net/netip"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// traceRecorder records the events of a [DialTrace].
+type traceRecorder struct {
+	mu     sync.Mutex
+	events []string
+	infos  []TFOInfo
+}
+
+func (r *traceRecorder) add(event string) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+}
+
+func (r *traceRecorder) trace() *DialTrace {
+	return &DialTrace{
+		DNSStart: func(host string) {
+			r.add("DNSStart " + host)
+		},
+		DNSDone: func(addrs []netip.Addr, err error) {
+			if err != nil {
+				r.add("DNSDone " + err.Error())
+				return
+			}
+			for _, addr := range addrs {
+				r.add("DNSDone " + addr.String())
+			}
+		},
+		ConnectStart: func(raddr netip.AddrPort) {
+			r.add("ConnectStart " + raddr.String())
+		},
+		ConnectDone: func(raddr netip.AddrPort, err error) {
+			if err != nil {
+				r.add("ConnectDone " + raddr.String() + " " + err.Error())
+				return
+			}
+			r.add("ConnectDone " + raddr.String())
+		},
+		RaceWon: func(raddr netip.AddrPort) {
+			r.add("RaceWon " + raddr.String())
+		},
+		Fallback: func(reason FallbackReason, _ error) {
+			r.add("Fallback " + reason.String())
+		},
+		PayloadSent: func(info TFOInfo) {
+			r.mu.Lock()
+			r.infos = append(r.infos, info)
+			r.mu.Unlock()
+		},
+	}
+}
+
+func TestDialTrace(t *testing.T) {
+	if comptimeDialNoTFO {
+		t.Skip("TFO is not supported on the current platform")
+	}
+
+	s, err := newDiscardTCPServer(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	raddr := s.AddrPort()
+
+	t.Run("Socket", func(t *testing.T) {
+		// On Linux, the sendto(MSG_FASTOPEN) path resolves names and races addresses in this package,
+		// like the TFO paths on other platforms.
+		d := Dialer{Fallback: true, SupportCache: new(SupportCache)}
+		runtimeFallbackSetDialLinuxSendto(d.SupportCache)
+
+		var r, outer traceRecorder
+		ctx := WithDialTrace(WithDialTrace(t.Context(), outer.trace()), r.trace())
+		c, err := d.DialContext(ctx, "tcp", raddr.String(), hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		info, _ := ConnTFOInfo(c)
+		info.SYNDataAcked, info.MultipathTCP = false, false
+
+		want := []string{
+			"ConnectStart " + raddr.String(),
+			"ConnectDone " + raddr.String(),
+			"RaceWon " + raddr.String(),
+		}
+		if runtime.GOOS == "linux" {
+			want = slices.Insert(want, 0, "Fallback "+FallbackReasonCachedSendto.String())
+		}
+
+		for _, rec := range []*traceRecorder{&r, &outer} {
+			if !slices.Equal(rec.events, want) {
+				t.Errorf("events = %q, want %q", rec.events, want)
+			}
+			if !slices.Equal(rec.infos, []TFOInfo{info}) {
+				t.Errorf("PayloadSent infos = %+v, want [%+v]", rec.infos, info)
+			}
+		}
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		// On Linux, the TCP_FASTOPEN_CONNECT path reports the same events as the other TFO paths.
+		d := Dialer{Fallback: true, SupportCache: new(SupportCache)}
+
+		var r traceRecorder
+		c, err := d.DialContext(WithDialTrace(t.Context(), r.trace()), "tcp", raddr.String(), hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		info, _ := ConnTFOInfo(c)
+		if runtime.GOOS == "linux" && info.Path != DialPathConnectOption {
+			t.Skipf("dialed with %v, want %v", info.Path, DialPathConnectOption)
+		}
+		info.SYNDataAcked, info.MultipathTCP = false, false
+
+		want := []string{
+			"ConnectStart " + raddr.String(),
+			"ConnectDone " + raddr.String(),
+			"RaceWon " + raddr.String(),
+		}
+		if !slices.Equal(r.events, want) {
+			t.Errorf("events = %q, want %q", r.events, want)
+		}
+		if !slices.Equal(r.infos, []TFOInfo{info}) {
+			t.Errorf("PayloadSent infos = %+v, want [%+v]", r.infos, info)
+		}
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		d := Dialer{Fallback: true, SupportCache: new(SupportCache)}
+
+		var r traceRecorder
+		c, err := d.DialContext(WithDialTrace(t.Context(), r.trace()), "tcp", net.JoinHostPort("localhost", strconv.Itoa(int(raddr.Port()))), hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		if len(r.events) < 2 || r.events[0] != "DNSStart localhost" || !strings.HasPrefix(r.events[1], "DNSDone ") {
+			t.Errorf("events = %q, want DNSStart localhost, then DNSDone", r.events)
+		}
+	})
+
+	t.Run("CachedNoTFO", func(t *testing.T) {
+		d := Dialer{Fallback: true, SupportCache: new(SupportCache)}
+		runtimeFallbackSetDialNoTFO(d.SupportCache)
+
+		var r traceRecorder
+		c, err := d.DialContext(WithDialTrace(t.Context(), r.trace()), "tcp", raddr.String(), hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		if want := []string{"Fallback " + FallbackReasonCachedNoTFO.String()}; !slices.Equal(r.events, want) {
+			t.Errorf("events = %q, want %q", r.events, want)
+		}
+		if want := []TFOInfo{{Path: DialPathPlain}}; !slices.Equal(r.infos, want) {
+			t.Errorf("PayloadSent infos = %+v, want %+v", r.infos, want)
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package tfo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DNSDialer dials connections to DNS servers for [net.Resolver.Dial],
+// so that DNS over TCP queries (RFC 7766) are sent in the SYN whenever possible.
+//
+// The resolver writes each length-prefixed TCP query with a single write,
+// which initiates the handshake of a [LazyConn] with the query as the payload.
+//
+// This only takes effect with the pure Go resolver, i.e. when [net.Resolver.PreferGo]
+// is set, or when the cgo resolver is not used.
+type DNSDialer struct {
+	// NetDialer is the optional dialer to use for the connections.
+	// A nil NetDialer is equivalent to the zero [Dialer].
+	NetDialer *Dialer
+
+	// Address is the optional address of the DNS server.
+	// If empty, the address requested by the resolver is used.
+	Address string
+
+	// AlwaysTCP controls whether to use TCP for queries the resolver would send over UDP.
+	AlwaysTCP bool
+}
+
+// Dial connects to a DNS server, and can be used as [net.Resolver.Dial].
+//
+// TCP connections defer connecting until the first query is written.
+// UDP connections are dialed with the embedded [net.Dialer],
+// unless [DNSDialer.AlwaysTCP] is set.
+func (d *DNSDialer) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	netDialer := d.NetDialer
+	if netDialer == nil {
+		netDialer = new(Dialer)
+	}
+	if d.Address != "" {
+		address = d.Address
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	case "udp", "udp4", "udp6":
+		if !d.AlwaysTCP {
+			return netDialer.Dialer.DialContext(ctx, network, address)
+		}
+		// The resolver uses stream framing for connections that are not [net.PacketConn].
+		network = "tcp" + network[len("udp"):]
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: net.UnknownNetworkError(network)}
+	}
+
+	c, err := netDialer.DialLazy(ctx, network, address, CoalesceConfig{})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+const (
+	defaultDNSServerIdleTimeout = 10 * time.Second
+	defaultDNSServerMaxInflight = 16
+)
+
+// DNSHandlerFunc handles a DNS query message, and returns the response message.
+// If the returned response is empty, no response is sent.
+type DNSHandlerFunc func(ctx context.Context, query []byte) []byte
+
+// DNSServer serves DNS over TCP (RFC 7766) on listeners with TFO enabled.
+//
+// Each connection may carry many pipelined queries, which are handled concurrently,
+// and responded to as soon as they are handled, possibly out of order.
+type DNSServer struct {
+	// Handler handles each query. It may be called concurrently.
+	Handler DNSHandlerFunc
+
+	// IdleTimeout is how long a connection may stay idle before it is closed.
+	// If zero, the default of 10 seconds is used.
+	IdleTimeout time.Duration
+
+	// MaxInflight is the maximum number of queries handled concurrently on each connection.
+	// Reading from the connection is paused when the limit is reached.
+	// If zero or negative, the default of 16 is used.
+	MaxInflight int
+}
+
+func (s *DNSServer) idleTimeout() time.Duration {
+	if s.IdleTimeout > 0 {
+		return s.IdleTimeout
+	}
+	return defaultDNSServerIdleTimeout
+}
+
+func (s *DNSServer) maxInflight() int {
+	if s.MaxInflight > 0 {
+		return s.MaxInflight
+	}
+	return defaultDNSServerMaxInflight
+}
+
+// ListenAndServe listens on the named network and address with lc, and serves DNS over TCP.
+// If lc is nil, the zero [ListenConfig] is used, which enables TFO whenever possible.
+//
+// See [DNSServer.Serve] for how it returns.
+func (s *DNSServer) ListenAndServe(ctx context.Context, lc *ListenConfig, network, address string) error {
+	if lc == nil {
+		lc = new(ListenConfig)
+	}
+	ln, err := lc.Listen(ctx, network, address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln, and serves DNS over TCP on each of them.
+//
+// It closes ln and all connections when ctx is canceled, and returns nil.
+// Temporary errors from accepting connections, such as running out of file descriptors,
+// are retried with backoff. Otherwise, it returns the first error from accepting connections,
+// after closing ln.
+func (s *DNSServer) Serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = ln.Close()
+	})
+	defer stop()
+	defer ln.Close()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var tempDelay time.Duration
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var tempErr interface{ Temporary() bool }
+			if !errors.As(err, &tempErr) || !tempErr.Temporary() {
+				return err
+			}
+			// Back off like net/http.Server.Serve does.
+			tempDelay = min(max(2*tempDelay, 5*time.Millisecond), time.Second)
+			timer := time.NewTimer(tempDelay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			}
+			continue
+		}
+		tempDelay = 0
+		wg.Go(func() {
+			s.serveConn(ctx, c)
+		})
+	}
+}
+
+// serveConn serves queries on c until the client closes it, an error occurs, or ctx is canceled.
+func (s *DNSServer) serveConn(ctx context.Context, c net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.Close()
+	})
+	defer stop()
+	defer c.Close()
+
+	var (
+		wg       sync.WaitGroup
+		writeMu  sync.Mutex
+		inflight = make(chan struct{}, s.maxInflight())
+		lenBuf   [2]byte
+	)
+	defer wg.Wait()
+
+	idleTimeout := s.idleTimeout()
+
+	for {
+		if err := c.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(c, lenBuf[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+		if _, err := io.ReadFull(c, query); err != nil {
+			return
+		}
+
+		select {
+		case inflight <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		wg.Go(func() {
+			defer func() { <-inflight }()
+
+			resp := s.Handler(ctx, query)
+			if len(resp) == 0 {
+				return
+			}
+			if len(resp) > 0xffff {
+				cancel()
+				return
+			}
+
+			b := make([]byte, 2, 2+len(resp))
+			binary.BigEndian.PutUint16(b, uint16(len(resp)))
+			b = append(b, resp...)
+
+			writeMu.Lock()
+			_, err := c.Write(b)
+			writeMu.Unlock()
+			if err != nil && !errors.Is(err, net.ErrClosed) {
+				cancel()
+			}
+		})
+	}
+}
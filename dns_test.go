@@ -0,0 +1,170 @@
+package tfo
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+var dnsTestAnswer = netip.MustParseAddr("192.0.2.53")
+
+// dnsTestHandler answers A queries with dnsTestAnswer, and other queries with no answers.
+func dnsTestHandler(_ context.Context, query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// Find the end of the question.
+	i := 12
+	for i < len(query) && query[i] != 0 {
+		i += 1 + int(query[i])
+	}
+	i += 1 + 4
+	if i > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[i-4:])
+
+	resp := make([]byte, 0, i+16)
+	resp = append(resp, query[0], query[1], 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0)
+	resp = append(resp, query[12:i]...)
+	if qtype == 1 {
+		resp[7] = 1
+		a := dnsTestAnswer.As4()
+		resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		resp = append(resp, a[:]...)
+	}
+	return resp
+}
+
+// appendDNSTestQuery appends a length-prefixed A query for example.com with id to b.
+func appendDNSTestQuery(b []byte, id uint16) []byte {
+	b = append(b, 0, 29, byte(id>>8), byte(id), 1, 0, 0, 1, 0, 0, 0, 0, 0, 0)
+	b = append(b, 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0)
+	return append(b, 0, 1, 0, 1)
+}
+
+func testDNSServerDialer(t *testing.T, lc ListenConfig, d Dialer) {
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	served := make(chan error, 1)
+	s := DNSServer{Handler: dnsTestHandler}
+	go func() {
+		served <- s.Serve(ctx, ln)
+	}()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("Serve() = %v, want nil", err)
+		}
+	}()
+
+	dd := DNSDialer{
+		NetDialer: &d,
+		Address:   ln.Addr().String(),
+		AlwaysTCP: true,
+	}
+
+	t.Run("Resolver", func(t *testing.T) {
+		r := net.Resolver{PreferGo: true, Dial: dd.Dial}
+		addrs, err := r.LookupNetIP(t.Context(), "ip4", "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []netip.Addr{dnsTestAnswer}; !slices.Equal(addrs, want) {
+			t.Errorf("addrs = %v, want %v", addrs, want)
+		}
+	})
+
+	t.Run("Pipelined", func(t *testing.T) {
+		c, err := dd.Dial(t.Context(), "tcp", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		const n = 32
+		var queries []byte
+		for id := range uint16(n) {
+			queries = appendDNSTestQuery(queries, id)
+		}
+		write(c, queries, t)
+
+		seen := make(map[uint16]bool)
+		for range n {
+			var lenBuf [2]byte
+			if _, err = io.ReadFull(c, lenBuf[:]); err != nil {
+				t.Fatal(err)
+			}
+			resp := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+			if _, err = io.ReadFull(c, resp); err != nil {
+				t.Fatal(err)
+			}
+			seen[binary.BigEndian.Uint16(resp)] = true
+		}
+		if len(seen) != n {
+			t.Errorf("got responses for %d distinct queries, want %d", len(seen), n)
+		}
+	})
+}
+
+func TestDNSServerDialer(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testDNSServerDialer)
+	}
+}
+
+// tempErrListener is a [net.Listener] whose Accept fails with a temporary error
+// the first n times.
+type tempErrListener struct {
+	net.Listener
+	n int
+}
+
+func (ln *tempErrListener) Accept() (net.Conn, error) {
+	if ln.n > 0 {
+		ln.n--
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Addr: ln.Addr(), Err: os.NewSyscallError("accept4", syscall.EMFILE)}
+	}
+	return ln.Listener.Accept()
+}
+
+func TestDNSServerAcceptTemporaryError(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	served := make(chan error, 1)
+	s := DNSServer{Handler: dnsTestHandler}
+	go func() {
+		served <- s.Serve(ctx, &tempErrListener{Listener: ln, n: 3})
+	}()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("Serve() = %v, want nil", err)
+		}
+	}()
+
+	dd := DNSDialer{Address: ln.Addr().String(), AlwaysTCP: true}
+	r := net.Resolver{PreferGo: true, Dial: dd.Dial}
+	addrs, err := r.LookupNetIP(t.Context(), "ip4", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []netip.Addr{dnsTestAnswer}; !slices.Equal(addrs, want) {
+		t.Errorf("addrs = %v, want %v", addrs, want)
+	}
+}
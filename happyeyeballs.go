@@ -0,0 +1,378 @@
+package tfo
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"time"
+)
+
+// defaultConnectionAttemptDelay is the recommended Connection Attempt Delay in RFC 8305 section 8.
+const defaultConnectionAttemptDelay = 250 * time.Millisecond
+
+// connectionAttemptDelay returns the delay between staggered connection attempts.
+// A negative value means connection attempts are made sequentially.
+func (d *Dialer) connectionAttemptDelay() time.Duration {
+	if d.FallbackDelay == 0 {
+		return defaultConnectionAttemptDelay
+	}
+	return d.FallbackDelay
+}
+
+// payloadAttemptDelay is like connectionAttemptDelay, but for connection attempts that each send bufs
+// during the dial. Unless [Dialer.RaceSYNData] is set, such attempts are made sequentially,
+// so that the payload is not sent to more than one server.
+func (d *Dialer) payloadAttemptDelay(bufs [][]byte) time.Duration {
+	if buffersLen(bufs) > 0 && !d.RaceSYNData {
+		return -1
+	}
+	return d.connectionAttemptDelay()
+}
+
+// localAddrPort returns d.LocalAddr as a [netip.AddrPort].
+func (d *Dialer) localAddrPort(network string) (netip.AddrPort, error) {
+	if d.LocalAddr == nil {
+		return netip.AddrPort{}, nil
+	}
+	la, ok := d.LocalAddr.(*net.TCPAddr)
+	if !ok {
+		return netip.AddrPort{}, &net.OpError{
+			Op:     "dial",
+			Net:    network,
+			Source: nil,
+			Addr:   nil,
+			Err: &net.AddrError{
+				Err:  "mismatched local address type",
+				Addr: d.LocalAddr.String(),
+			},
+		}
+	}
+	return la.AddrPort(), nil
+}
+
+// wrapDialError wraps err in a [*net.OpError], unless it already is one.
+func (d *Dialer) wrapDialError(network string, raddr net.Addr, err error) error {
+	if _, ok := err.(*net.OpError); ok {
+		return err
+	}
+	return &net.OpError{Op: "dial", Net: network, Source: d.LocalAddr, Addr: raddr, Err: err}
+}
+
+// sortAddrPorts returns a copy of raddrs suitable for dialing on network from laddr,
+// sorted by RFC 6724 and interleaved by address family as described in RFC 8305 section 4.
+func sortAddrPorts(network string, laddr netip.Addr, raddrs []netip.AddrPort) []netip.AddrPort {
+	laddr = laddr.Unmap()
+	addrs := make([]netip.AddrPort, 0, len(raddrs))
+	for _, raddr := range raddrs {
+		addr := raddr.Addr().Unmap()
+		switch {
+		case network == "tcp4" && !addr.Is4(),
+			network == "tcp6" && !addr.Is6(),
+			laddr.IsValid() && !laddr.IsUnspecified() && laddr.Is4() != addr.Is4():
+			continue
+		}
+		addrs = append(addrs, netip.AddrPortFrom(addr, raddr.Port()))
+	}
+	sortByRFC6724(addrs)
+	return interleaveAddrFamilies(addrs)
+}
+
+// interleaveAddrFamilies reorders addrs so that address families alternate,
+// starting with the family of the first address.
+// The relative order of addresses within each family is preserved.
+func interleaveAddrFamilies(addrs []netip.AddrPort) []netip.AddrPort {
+	if len(addrs) < 2 {
+		return addrs
+	}
+	firstIs4 := addrs[0].Addr().Is4()
+	primaries, fallbacks := make([]netip.AddrPort, 0, len(addrs)), make([]netip.AddrPort, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr.Addr().Is4() == firstIs4 {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	if len(fallbacks) == 0 {
+		return addrs
+	}
+	interleaved := addrs[:0]
+	for len(primaries) > 0 || len(fallbacks) > 0 {
+		if len(primaries) > 0 {
+			interleaved = append(interleaved, primaries[0])
+			primaries = primaries[1:]
+		}
+		if len(fallbacks) > 0 {
+			interleaved = append(interleaved, fallbacks[0])
+			fallbacks = fallbacks[1:]
+		}
+	}
+	return interleaved
+}
+
+// dialHappyEyeballs connects to raddrs in order, following RFC 8305 section 5.
+//
+// A new connection attempt is started every delay, or as soon as the previous attempt fails,
+// without waiting for earlier attempts to finish. The first established connection is returned,
+// and all other attempts are canceled. If all attempts fail, the error from the first address
+// is returned. If delay is negative, connection attempts are made sequentially.
+//
+// raddrs must not be empty.
+func dialHappyEyeballs(ctx context.Context, raddrs []netip.AddrPort, delay time.Duration, dialOne func(context.Context, netip.AddrPort) (*net.TCPConn, error)) (*net.TCPConn, error) {
+	if delay < 0 || len(raddrs) == 1 {
+		return dialSequential(ctx, raddrs, dialOne)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		*net.TCPConn
+		error
+		index int
+	}
+	// Buffered, so that attempts never block on sending their results after we return.
+	results := make(chan dialResult, len(raddrs))
+
+	trace := ContextDialTrace(ctx)
+	var next, pending int
+	startAttempt := func() {
+		i := next
+		next++
+		pending++
+		go func() {
+			trace.connectStart(raddrs[i])
+			c, err := dialOne(ctx, raddrs[i])
+			trace.connectDone(raddrs[i], err)
+			results <- dialResult{TCPConn: c, error: err, index: i}
+		}()
+	}
+
+	startAttempt()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var (
+		firstErr      error
+		firstErrIndex = len(raddrs)
+	)
+
+	for {
+		select {
+		case <-timer.C:
+			if next < len(raddrs) && ctx.Err() == nil {
+				startAttempt()
+				timer.Reset(delay)
+			}
+
+		case res := <-results:
+			pending--
+			if res.error == nil {
+				cancel()
+				// Close connections established by attempts that lost the race.
+				go func(pending int) {
+					for range pending {
+						if res := <-results; res.TCPConn != nil {
+							res.Close()
+						}
+					}
+				}(pending)
+				trace.raceWon(raddrs[res.index])
+				return res.TCPConn, nil
+			}
+			if res.index < firstErrIndex {
+				firstErr, firstErrIndex = res.error, res.index
+			}
+			if next < len(raddrs) && ctx.Err() == nil {
+				startAttempt()
+				timer.Reset(delay)
+				continue
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// dialSequential connects to raddrs in sequence, returning
+// either the first successful connection, or the first error.
+//
+// raddrs must not be empty.
+func dialSequential(ctx context.Context, raddrs []netip.AddrPort, dialOne func(context.Context, netip.AddrPort) (*net.TCPConn, error)) (*net.TCPConn, error) {
+	var firstErr error // The error from the first address is most relevant.
+	trace := ContextDialTrace(ctx)
+
+	for i, raddr := range raddrs {
+		if err := ctx.Err(); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			break
+		}
+
+		dialCtx := ctx
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+			partialDeadline, err := partialDeadline(time.Now(), deadline, len(raddrs)-i)
+			if err != nil {
+				// Ran out of time.
+				if firstErr == nil {
+					firstErr = err
+				}
+				break
+			}
+			if partialDeadline.Before(deadline) {
+				var cancel context.CancelFunc
+				dialCtx, cancel = context.WithDeadline(ctx, partialDeadline)
+				defer cancel()
+			}
+		}
+
+		trace.connectStart(raddr)
+		c, err := dialOne(dialCtx, raddr)
+		trace.connectDone(raddr, err)
+		if err == nil {
+			trace.raceWon(raddr)
+			return c, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, firstErr
+}
+
+// DialAddrPorts connects to one of the pre-resolved addresses in raddrs on the named network,
+// sending b in SYN whenever possible, like [Dialer.DialTCP].
+//
+// The addresses are sorted according to RFC 6724 and interleaved by address family,
+// then raced using Happy Eyeballs Version 2 (RFC 8305): a new connection attempt
+// is started every [net.Dialer.FallbackDelay] (250ms if zero), or as soon as the previous
+// attempt fails. The first established connection is returned, and all other attempts
+// are canceled. If FallbackDelay is negative, the addresses are tried sequentially.
+//
+// Each attempt sends b during the dial. Unless [Dialer.RaceSYNData] is set, the addresses
+// are tried sequentially when b is not empty, so that b is not sent to more than one server.
+//
+// Addresses that do not match the network or the family of [net.Dialer.LocalAddr] are skipped.
+// raddrs is not modified.
+func (d *Dialer) DialAddrPorts(ctx context.Context, network string, raddrs []netip.AddrPort, b []byte) (*net.TCPConn, error) {
+	if !networkIsTCP(network) {
+		return nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: net.UnknownNetworkError(network)}
+	}
+
+	ctx, cancel := d.dialCtx(ctx)
+	defer cancel()
+
+	laddr, err := d.localAddrPort(network)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := sortAddrPorts(network, laddr.Addr(), raddrs)
+	if len(addrs) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Source: d.LocalAddr, Addr: nil, Err: errMissingAddress}
+	}
+
+	if d.DisableTFO || len(b) == 0 {
+		// Like DialTCP, only dial calls that attempted TFO are counted.
+		ctx = withoutStats(ctx)
+	}
+	start := time.Now()
+	// The attempts are recorded once, as this dial call.
+	c, err := dialHappyEyeballs(withNestedDial(ctx), addrs, d.payloadAttemptDelay([][]byte{b}), func(ctx context.Context, raddr netip.AddrPort) (*net.TCPConn, error) {
+		return d.DialTCP(ctx, network, laddr, raddr, b)
+	})
+	recordDialCall(ctx, start, c, err)
+	if err != nil {
+		return nil, d.wrapDialError(network, nil, err)
+	}
+	return c, nil
+}
+
+func (d *Dialer) dialCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		panic("nil context")
+	}
+	deadline := d.deadline(ctx, time.Now())
+	var cancel1, cancel2 context.CancelFunc
+	if !deadline.IsZero() {
+		if d, ok := ctx.Deadline(); !ok || deadline.Before(d) {
+			var subCtx context.Context
+			subCtx, cancel1 = context.WithDeadline(ctx, deadline)
+			ctx = subCtx
+		}
+	}
+	if oldCancel := d.Cancel; oldCancel != nil {
+		var subCtx context.Context
+		subCtx, cancel2 = context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-oldCancel:
+				cancel2()
+			case <-subCtx.Done():
+			}
+		}()
+		ctx = subCtx
+	}
+	return ctx, func() {
+		if cancel1 != nil {
+			cancel1()
+		}
+		if cancel2 != nil {
+			cancel2()
+		}
+	}
+}
+
+func minNonzeroTime(a, b time.Time) time.Time {
+	if a.IsZero() {
+		return b
+	}
+	if b.IsZero() || a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// deadline returns the earliest of:
+//   - now+Timeout
+//   - d.Deadline
+//   - the context's deadline
+//
+// Or zero, if none of Timeout, Deadline, or context's deadline is set.
+func (d *Dialer) deadline(ctx context.Context, now time.Time) (earliest time.Time) {
+	if d.Timeout != 0 { // including negative, for historical reasons
+		earliest = now.Add(d.Timeout)
+	}
+	if d, ok := ctx.Deadline(); ok {
+		earliest = minNonzeroTime(earliest, d)
+	}
+	return minNonzeroTime(earliest, d.Deadline)
+}
+
+// partialDeadline returns the deadline to use for a single address,
+// when multiple addresses are pending.
+func partialDeadline(now, deadline time.Time, addrsRemaining int) (time.Time, error) {
+	if deadline.IsZero() {
+		return deadline, nil
+	}
+	timeRemaining := deadline.Sub(now)
+	if timeRemaining <= 0 {
+		return time.Time{}, os.ErrDeadlineExceeded
+	}
+	// Tentatively allocate equal time to each remaining address.
+	timeout := timeRemaining / time.Duration(addrsRemaining)
+	// If the time per address is too short, steal from the end of the list.
+	const saneMinimum = 2 * time.Second
+	if timeout < saneMinimum {
+		if timeRemaining < saneMinimum {
+			timeout = timeRemaining
+		} else {
+			timeout = saneMinimum
+		}
+	}
+	return now.Add(timeout), nil
+}
@@ -0,0 +1,286 @@
+package tfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var (
+	heV4a = netip.MustParseAddrPort("192.0.2.1:443")
+	heV4b = netip.MustParseAddrPort("192.0.2.2:443")
+	heV6a = netip.MustParseAddrPort("[2001:db8::1]:443")
+	heV6b = netip.MustParseAddrPort("[2001:db8::2]:443")
+	heV6c = netip.MustParseAddrPort("[2001:db8::3]:443")
+)
+
+func TestInterleaveAddrFamilies(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		addrs []netip.AddrPort
+		want  []netip.AddrPort
+	}{
+		{"Empty", nil, nil},
+		{"Single", []netip.AddrPort{heV6a}, []netip.AddrPort{heV6a}},
+		{"SameFamily", []netip.AddrPort{heV6a, heV6b, heV6c}, []netip.AddrPort{heV6a, heV6b, heV6c}},
+		{"IPv6First", []netip.AddrPort{heV6a, heV6b, heV6c, heV4a, heV4b}, []netip.AddrPort{heV6a, heV4a, heV6b, heV4b, heV6c}},
+		{"IPv4First", []netip.AddrPort{heV4a, heV4b, heV6a}, []netip.AddrPort{heV4a, heV6a, heV4b}},
+		{"Mixed", []netip.AddrPort{heV6a, heV4a, heV4b, heV6b}, []netip.AddrPort{heV6a, heV4a, heV6b, heV4b}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got := interleaveAddrFamilies(slices.Clone(c.addrs))
+			if !slices.Equal(got, c.want) {
+				t.Errorf("interleaveAddrFamilies(%v) = %v, want %v", c.addrs, got, c.want)
+			}
+		})
+	}
+}
+
+func TestSortAddrPortsFilter(t *testing.T) {
+	raddrs := []netip.AddrPort{
+		heV6a,
+		netip.AddrPortFrom(netip.AddrFrom16(heV4a.Addr().As16()), heV4a.Port()),
+		heV4b,
+	}
+	orig := slices.Clone(raddrs)
+
+	for _, c := range []struct {
+		name    string
+		network string
+		laddr   netip.Addr
+		want    []netip.AddrPort
+	}{
+		{"tcp", "tcp", netip.Addr{}, []netip.AddrPort{heV6a, heV4a, heV4b}},
+		{"tcp4", "tcp4", netip.Addr{}, []netip.AddrPort{heV4a, heV4b}},
+		{"tcp6", "tcp6", netip.Addr{}, []netip.AddrPort{heV6a}},
+		{"LocalAddrIPv4", "tcp", netip.MustParseAddr("127.0.0.1"), []netip.AddrPort{heV4a, heV4b}},
+		{"LocalAddrIPv6", "tcp", netip.MustParseAddr("::1"), []netip.AddrPort{heV6a}},
+		{"LocalAddrUnspecified", "tcp", netip.IPv6Unspecified(), []netip.AddrPort{heV6a, heV4a, heV4b}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got := sortAddrPorts(c.network, c.laddr, raddrs)
+			for _, addr := range c.want {
+				if !slices.Contains(got, addr) {
+					t.Errorf("sortAddrPorts() = %v, missing %v", got, addr)
+				}
+			}
+			if len(got) != len(c.want) {
+				t.Errorf("sortAddrPorts() = %v, want %d addresses", got, len(c.want))
+			}
+		})
+	}
+
+	if !slices.Equal(raddrs, orig) {
+		t.Errorf("raddrs modified: got %v, want %v", raddrs, orig)
+	}
+}
+
+// dialDiscardServer returns a dialOne function that connects to s for raddr,
+// or calls fail for any other address.
+func dialDiscardServer(s *discardTCPServer, raddr netip.AddrPort, fail func(context.Context) error) func(context.Context, netip.AddrPort) (*net.TCPConn, error) {
+	return func(ctx context.Context, addr netip.AddrPort) (*net.TCPConn, error) {
+		if addr != raddr {
+			return nil, fail(ctx)
+		}
+		var d net.Dialer
+		return d.DialTCP(ctx, "tcp", netip.AddrPort{}, s.AddrPort())
+	}
+}
+
+func TestDialHappyEyeballs(t *testing.T) {
+	s, err := newDiscardTCPServer(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	errRefused := errors.New("refused")
+	raddrs := []netip.AddrPort{heV6a, heV4a, heV6b, heV4b}
+
+	t.Run("StaggeredStart", func(t *testing.T) {
+		// Every attempt hangs except the last one, which must be started after 3 delays.
+		canceled := make(chan struct{}, len(raddrs))
+		const delay = 20 * time.Millisecond
+		start := time.Now()
+		c, err := dialHappyEyeballs(t.Context(), raddrs, delay, dialDiscardServer(s, heV4b, func(ctx context.Context) error {
+			<-ctx.Done()
+			canceled <- struct{}{}
+			return ctx.Err()
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if elapsed := time.Since(start); elapsed < 3*delay {
+			t.Errorf("elapsed = %v, want >= %v", elapsed, 3*delay)
+		}
+		// The losers must be canceled.
+		for range len(raddrs) - 1 {
+			<-canceled
+		}
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		// Failed attempts start the next attempt immediately, without waiting for the delay.
+		c, err := dialHappyEyeballs(t.Context(), raddrs, time.Hour, dialDiscardServer(s, heV4b, func(context.Context) error {
+			return errRefused
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	})
+
+	t.Run("Sequential", func(t *testing.T) {
+		var attempts []netip.AddrPort
+		c, err := dialHappyEyeballs(t.Context(), raddrs, -1, func(ctx context.Context, addr netip.AddrPort) (*net.TCPConn, error) {
+			attempts = append(attempts, addr)
+			return dialDiscardServer(s, heV6b, func(context.Context) error { return errRefused })(ctx, addr)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		if want := raddrs[:3]; !slices.Equal(attempts, want) {
+			t.Errorf("attempts = %v, want %v", attempts, want)
+		}
+	})
+
+	t.Run("FirstError", func(t *testing.T) {
+		errFirst := errors.New("first")
+		for _, delay := range []time.Duration{-1, 0, time.Millisecond} {
+			_, err := dialHappyEyeballs(t.Context(), raddrs, delay, func(ctx context.Context, addr netip.AddrPort) (*net.TCPConn, error) {
+				if addr == raddrs[0] {
+					// Fail last, so that the first error is not the first to arrive.
+					time.Sleep(10 * time.Millisecond)
+					return nil, errFirst
+				}
+				return nil, errRefused
+			})
+			if err != errFirst {
+				t.Errorf("delay %v: err = %v, want %v", delay, err, errFirst)
+			}
+		}
+	})
+}
+
+func testDialAddrPorts(t *testing.T, lc ListenConfig, d Dialer) {
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		readUntilEOF(conn, hello, t)
+		write(conn, world, t)
+	}()
+
+	// 192.0.2.1 is reserved for documentation, and is not expected to be reachable.
+	// Refused connections cannot be used here, as with a cached TFO cookie,
+	// the dial call may return before the connection is refused.
+	raddrs := []netip.AddrPort{heV4a, ln.Addr().(*net.TCPAddr).AddrPort()}
+	orig := slices.Clone(raddrs)
+
+	c, err := d.DialAddrPorts(t.Context(), "tcp", raddrs, hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err = c.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	readUntilEOF(c, world, t)
+	<-done
+
+	if !slices.Equal(raddrs, orig) {
+		t.Errorf("raddrs modified: got %v, want %v", raddrs, orig)
+	}
+}
+
+// TestDialAddrPorts ensures that [Dialer.DialAddrPorts] connects to a reachable address
+// when the list also contains an unreachable one.
+func TestDialAddrPorts(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testDialAddrPorts)
+	}
+}
+
+func TestDialAddrPortsNoAddress(t *testing.T) {
+	var d Dialer
+	_, err := d.DialAddrPorts(t.Context(), "tcp4", []netip.AddrPort{heV6a}, hello)
+	if !errors.Is(err, errMissingAddress) {
+		t.Errorf("err = %v, want %v", err, errMissingAddress)
+	}
+}
+
+// TestDialAddrPortsPayloadSequential ensures that connection attempts sending a payload
+// are only raced with [Dialer.RaceSYNData].
+func TestDialAddrPortsPayloadSequential(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	raddr := ln.Addr().(*net.TCPAddr).AddrPort()
+	slow := netip.AddrPortFrom(netip.IPv6Loopback(), raddr.Port())
+	slowErr := errors.New("slow attempt failed")
+
+	for _, race := range []bool{false, true} {
+		d := Dialer{RaceSYNData: race}
+		d.FallbackDelay = 10 * time.Millisecond
+		// Fail the attempt to slow only after the next attempt would have been started by a race.
+		d.ControlContext = func(ctx context.Context, _, address string, _ syscall.RawConn) error {
+			if address != slow.String() {
+				return nil
+			}
+			select {
+			case <-time.After(100 * time.Millisecond):
+				return slowErr
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		var r traceRecorder
+		c, err := d.DialAddrPorts(WithDialTrace(t.Context(), r.trace()), "tcp", []netip.AddrPort{slow, raddr}, hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+
+		slowDone := slices.IndexFunc(r.events, func(e string) bool {
+			return strings.HasPrefix(e, "ConnectDone "+slow.String())
+		})
+		nextStart := slices.Index(r.events, "ConnectStart "+raddr.String())
+		// A raced attempt to slow may only end after the dial call returns.
+		if raced := slowDone < 0 || nextStart < slowDone; raced != race {
+			t.Errorf("RaceSYNData = %t: events = %q", race, r.events)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package tfo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// maxHTTPConnectResponseHeaderBytes limits the size of the proxy's response header.
+const maxHTTPConnectResponseHeaderBytes = 64 << 10
+
+// ErrHTTPConnectMalformedResponse is returned when an HTTP proxy's response
+// to a CONNECT request cannot be parsed.
+var ErrHTTPConnectMalformedResponse = errors.New("http connect: malformed proxy response")
+
+// HTTPConnectError is returned when an HTTP proxy responds to a CONNECT request
+// with a non-2xx status code.
+type HTTPConnectError struct {
+	// StatusCode is the status code of the response, e.g. 407.
+	StatusCode int
+
+	// Status is the status line of the response, e.g. "407 Proxy Authentication Required".
+	Status string
+
+	// Header is the header of the response.
+	Header http.Header
+}
+
+// Error implements [error].
+func (e *HTTPConnectError) Error() string {
+	return "http connect: proxy responded with " + e.Status
+}
+
+// HTTPConnectDialer connects to targets through an HTTP proxy with the CONNECT method,
+// sending the CONNECT request in the SYN whenever possible.
+type HTTPConnectDialer struct {
+	// NetDialer is the optional dialer to use for the connections to the proxy.
+	// A nil NetDialer is equivalent to the zero [Dialer].
+	NetDialer *Dialer
+
+	// ProxyNetwork is the network of the proxy. If empty, "tcp" is used.
+	ProxyNetwork string
+
+	// ProxyAddress is the address of the proxy.
+	ProxyAddress string
+
+	// Username and Password are the optional credentials for
+	// the Proxy-Authorization header with the Basic scheme.
+	// If Username is empty, no Proxy-Authorization header is sent,
+	// unless one is set in Header.
+	Username string
+	Password string
+
+	// Header is the optional header to send with CONNECT requests.
+	Header http.Header
+}
+
+func (d *HTTPConnectDialer) netDialer() *Dialer {
+	if d.NetDialer != nil {
+		return d.NetDialer
+	}
+	return new(Dialer)
+}
+
+func (d *HTTPConnectDialer) proxyNetwork() string {
+	if d.ProxyNetwork != "" {
+		return d.ProxyNetwork
+	}
+	return "tcp"
+}
+
+// Dial is like [HTTPConnectDialer.DialContext] but uses [context.Background].
+func (d *HTTPConnectDialer) Dial(network, address string, b []byte) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address, b)
+}
+
+// DialContext connects to address on the named network through the proxy,
+// and returns the tunneled connection to the target. b is written to the target
+// after the proxy has responded with a 2xx status code.
+//
+// Any bytes the proxy sent after the response header are returned by the first
+// reads from the returned connection.
+//
+// The network must be a TCP network name. The address may be a domain name,
+// which is resolved by the proxy.
+func (d *HTTPConnectDialer) DialContext(ctx context.Context, network, address string, b []byte) (net.Conn, error) {
+	if !networkIsTCP(network) {
+		return nil, &net.OpError{Op: "http connect", Net: network, Source: nil, Addr: nil, Err: net.UnknownNetworkError(network)}
+	}
+
+	hdr := d.Header
+	if hdr == nil {
+		hdr = make(http.Header)
+	}
+	if d.Username != "" {
+		hdr = hdr.Clone()
+		hdr.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(d.Username+":"+d.Password)))
+	}
+	connectReq := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: hdr,
+	}
+
+	var reqBuf bytes.Buffer
+	if err := connectReq.Write(&reqBuf); err != nil {
+		return nil, &net.OpError{Op: "http connect", Net: network, Source: nil, Addr: nil, Err: err}
+	}
+
+	netDialer := d.netDialer()
+	ctx, cancel := netDialer.dialCtx(ctx)
+	defer cancel()
+
+	c, err := netDialer.DialContext(ctx, d.proxyNetwork(), d.ProxyAddress, reqBuf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.SetDeadline(aLongTimeAgo)
+	})
+	conn, err := readHTTPConnectResponse(c, connectReq)
+	if err == nil && len(b) > 0 {
+		_, err = c.Write(b)
+	}
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		return nil, &net.OpError{Op: "http connect", Net: network, Source: c.RemoteAddr(), Addr: nil, Err: err}
+	}
+	return conn, nil
+}
+
+// readHTTPConnectResponse reads the proxy's response to req from c,
+// and returns the tunneled connection.
+func readHTTPConnectResponse(c net.Conn, req *http.Request) (net.Conn, error) {
+	lr := &io.LimitedReader{R: c, N: maxHTTPConnectResponseHeaderBytes}
+	br := bufio.NewReader(lr)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		// The header did not end within the limit.
+		if lr.N == 0 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			return nil, fmt.Errorf("%w: header exceeds %d bytes: %w", ErrHTTPConnectMalformedResponse, maxHTTPConnectResponseHeaderBytes, err)
+		}
+		var netErr net.Error
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrHTTPConnectMalformedResponse, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPConnectError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header,
+		}
+	}
+
+	if n := br.Buffered(); n > 0 {
+		buf, _ := br.Peek(n)
+		return &bufferedConn{Conn: c, buf: bytes.Clone(buf)}, nil
+	}
+	return c, nil
+}
+
+// bufferedConn is a [net.Conn] that returns buf before reading from the underlying connection.
+type bufferedConn struct {
+	net.Conn
+	buf []byte
+}
+
+// Read implements [net.Conn.Read].
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	if len(c.buf) > 0 {
+		n := copy(b, c.buf)
+		c.buf = c.buf[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+// WriteTo implements [io.WriterTo].
+func (c *bufferedConn) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	if len(c.buf) > 0 {
+		nn, err := w.Write(c.buf)
+		n += int64(nn)
+		c.buf = c.buf[nn:]
+		if err != nil {
+			return n, err
+		}
+	}
+	nn, err := io.Copy(w, c.Conn)
+	return n + nn, err
+}
+
+// CloseRead closes the read side of the underlying connection, if supported.
+func (c *bufferedConn) CloseRead() error {
+	if cr, ok := c.Conn.(interface{ CloseRead() error }); ok {
+		return cr.CloseRead()
+	}
+	return errors.ErrUnsupported
+}
+
+// CloseWrite closes the write side of the underlying connection, if supported.
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return errors.ErrUnsupported
+}
+
+// NetConn returns the underlying connection.
+func (c *bufferedConn) NetConn() net.Conn {
+	return c.Conn
+}
@@ -0,0 +1,165 @@
+package tfo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// newHTTPConnectTestServer starts an in-process HTTP CONNECT proxy stand-in.
+// respond writes the response to the CONNECT request, and returns whether to tunnel.
+func newHTTPConnectTestServer(t *testing.T, lc ListenConfig, respond func(w io.Writer, req *http.Request) bool) net.Listener {
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				req, err := http.ReadRequest(br)
+				if err != nil || req.Method != "CONNECT" {
+					return
+				}
+				if !respond(conn, req) {
+					return
+				}
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go func() {
+					io.Copy(target, br)
+					target.(*net.TCPConn).CloseWrite()
+				}()
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return ln
+}
+
+func testHTTPConnectDialer(t *testing.T, lc ListenConfig, d Dialer) {
+	target, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		readUntilEOF(conn, helloworld, t)
+		write(conn, hello, t)
+	}()
+
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	ln := newHTTPConnectTestServer(t, lc, func(w io.Writer, req *http.Request) bool {
+		if auth := req.Header.Get("Proxy-Authorization"); auth != wantAuth {
+			t.Errorf("Proxy-Authorization = %q, want %q", auth, wantAuth)
+		}
+		// Send bytes right after the header, which must be preserved.
+		io.WriteString(w, "HTTP/1.1 200 Connection established\r\n\r\nworld")
+		return true
+	})
+	defer ln.Close()
+
+	hd := HTTPConnectDialer{
+		NetDialer:    &d,
+		ProxyAddress: ln.Addr().String(),
+		Username:     "user",
+		Password:     "pass",
+	}
+	c, err := hd.DialContext(t.Context(), "tcp", target.Addr().String(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	write(c, world, t)
+	if err = c.(interface{ CloseWrite() error }).CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	readUntilEOF(c, worldhello, t)
+}
+
+func TestHTTPConnectDialer(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testHTTPConnectDialer)
+	}
+}
+
+func TestHTTPConnectDialerErrors(t *testing.T) {
+	t.Run("Status", func(t *testing.T) {
+		ln := newHTTPConnectTestServer(t, ListenConfig{}, func(w io.Writer, req *http.Request) bool {
+			io.WriteString(w, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic\r\nContent-Length: 0\r\n\r\n")
+			return false
+		})
+		defer ln.Close()
+
+		hd := HTTPConnectDialer{ProxyAddress: ln.Addr().String()}
+		c, err := hd.DialContext(t.Context(), "tcp", "192.0.2.1:443", hello)
+		if err == nil {
+			c.Close()
+		}
+		var connectErr *HTTPConnectError
+		if !errors.As(err, &connectErr) {
+			t.Fatalf("err = %v, want %T", err, connectErr)
+		}
+		if connectErr.StatusCode != http.StatusProxyAuthRequired {
+			t.Errorf("StatusCode = %d, want %d", connectErr.StatusCode, http.StatusProxyAuthRequired)
+		}
+		if got := connectErr.Header.Get("Proxy-Authenticate"); got != "Basic" {
+			t.Errorf("Proxy-Authenticate = %q, want %q", got, "Basic")
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		ln := newHTTPConnectTestServer(t, ListenConfig{}, func(w io.Writer, req *http.Request) bool {
+			io.WriteString(w, "SSH-2.0-OpenSSH_9.9\r\n\r\n")
+			return false
+		})
+		defer ln.Close()
+
+		hd := HTTPConnectDialer{ProxyAddress: ln.Addr().String()}
+		c, err := hd.DialContext(t.Context(), "tcp", "192.0.2.1:443", hello)
+		if err == nil {
+			c.Close()
+		}
+		if !errors.Is(err, ErrHTTPConnectMalformedResponse) {
+			t.Errorf("err = %v, want %v", err, ErrHTTPConnectMalformedResponse)
+		}
+	})
+	t.Run("HeaderTooLarge", func(t *testing.T) {
+		ln := newHTTPConnectTestServer(t, ListenConfig{}, func(w io.Writer, req *http.Request) bool {
+			io.WriteString(w, "HTTP/1.1 200 Connection established\r\nX-Padding: ")
+			w.Write(bytes.Repeat([]byte{'a'}, maxHTTPConnectResponseHeaderBytes))
+			io.WriteString(w, "\r\n\r\n")
+			return false
+		})
+		defer ln.Close()
+
+		hd := HTTPConnectDialer{ProxyAddress: ln.Addr().String()}
+		c, err := hd.DialContext(t.Context(), "tcp", "192.0.2.1:443", hello)
+		if err == nil {
+			c.Close()
+		}
+		if !errors.Is(err, ErrHTTPConnectMalformedResponse) {
+			t.Errorf("err = %v, want %v", err, ErrHTTPConnectMalformedResponse)
+		}
+	})
+}
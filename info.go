@@ -0,0 +1,133 @@
+package tfo
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// DialPath identifies how a dial call sent its initial payload.
+type DialPath uint8
+
+const (
+	// DialPathPlain means the connection was established without TFO,
+	// and the payload was written after the handshake.
+	DialPathPlain DialPath = iota + 1
+
+	// DialPathConnectOption means the socket had TCP_FASTOPEN_CONNECT set before connect(2) (Linux),
+	// and the payload was sent with the first write(2).
+	DialPathConnectOption
+
+	// DialPathSendmsg means the payload was sent with sendmsg(2) on an unconnected socket,
+	// using MSG_FASTOPEN on Linux, or TCP_FASTOPEN on FreeBSD.
+	DialPathSendmsg
+
+	// DialPathConnectx means the payload was sent with connectx(2) (macOS).
+	DialPathConnectx
+
+	// DialPathConnectEx means the payload was sent with ConnectEx (Windows).
+	DialPathConnectEx
+)
+
+// String implements [fmt.Stringer].
+func (p DialPath) String() string {
+	switch p {
+	case DialPathPlain:
+		return "plain"
+	case DialPathConnectOption:
+		return "TCP_FASTOPEN_CONNECT"
+	case DialPathSendmsg:
+		return "sendmsg"
+	case DialPathConnectx:
+		return "connectx"
+	case DialPathConnectEx:
+		return "ConnectEx"
+	default:
+		return "unknown"
+	}
+}
+
+// TFOInfo reports how a connection returned by [Dialer] sent its initial payload.
+type TFOInfo struct {
+	// Path is the code path taken to send the initial payload.
+	Path DialPath
+
+	// SYNBytes is the number of payload bytes accepted by the kernel
+	// in the call that initiated the handshake.
+	//
+	// On Linux, this is the number of bytes carried in the SYN.
+	// It is 0 when the kernel did not have a TFO cookie for the server,
+	// and sent a cookie request instead.
+	SYNBytes int
+
+	// SYNDataAcked reports whether the server acknowledged the data in the SYN.
+	//
+	// This is only supported on Linux, where it is read from tcpi_options in TCP_INFO
+	// when [ConnTFOInfo] is called. The handshake may still be in progress when
+	// the dial call returns, so callers interested in this field should query it
+	// after receiving data from the server.
+	SYNDataAcked bool
+
+	// MultipathTCP reports whether the connection is using Multipath TCP,
+	// as reported by [net.TCPConn.MultipathTCP] when [ConnTFOInfo] is called.
+	//
+	// A dialer that requests Multipath TCP may end up with a plain TCP connection,
+	// when the kernel refuses to create an MPTCP socket, or when the server does not support it.
+	MultipathTCP bool
+}
+
+// connTFOInfos maps weak pointers of dialed connections to their [TFOInfo].
+var connTFOInfos sync.Map // map[weak.Pointer[net.TCPConn]]TFOInfo
+
+// setConnTFOInfo records info for c. The record is removed when c is garbage collected.
+//
+// The info is only counted in package stats and reported to the [DialTrace] by [reportPayloadSent],
+// once c is returned by a dial call.
+func setConnTFOInfo(c *net.TCPConn, info TFOInfo) {
+	wp := weak.Make(c)
+	connTFOInfos.Store(wp, info)
+	runtime.AddCleanup(c, func(wp weak.Pointer[net.TCPConn]) {
+		connTFOInfos.Delete(wp)
+	}, wp)
+}
+
+// reportPayloadSent records the info of c, the connection returned by a dial call, in package stats,
+// and reports it to the [DialTrace] of ctx.
+func reportPayloadSent(ctx context.Context, c *net.TCPConn) {
+	v, ok := connTFOInfos.Load(weak.Make(c))
+	if !ok {
+		return
+	}
+	info := v.(TFOInfo)
+	recordPayloadSent(ctx, info)
+	ContextDialTrace(ctx).payloadSent(info)
+}
+
+// ConnTFOInfo returns the [TFOInfo] of a connection returned by [Dialer],
+// or any connection that wraps one and has a NetConn method.
+//
+// ok is false if c was not dialed by this package with a non-empty payload.
+func ConnTFOInfo(c net.Conn) (info TFOInfo, ok bool) {
+	for {
+		if tc, isTCPConn := c.(*net.TCPConn); isTCPConn {
+			v, loaded := connTFOInfos.Load(weak.Make(tc))
+			if !loaded {
+				return TFOInfo{}, false
+			}
+			info = v.(TFOInfo)
+			if info.Path != DialPathPlain {
+				info.SYNDataAcked = synDataAcked(tc) // info_linux.go, info_stub.go
+			}
+			info.MultipathTCP, _ = tc.MultipathTCP()
+			return info, true
+		}
+
+		nc, isWrapper := c.(interface{ NetConn() net.Conn })
+		if !isWrapper {
+			return TFOInfo{}, false
+		}
+		c = nc.NetConn()
+	}
+}
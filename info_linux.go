@@ -0,0 +1,73 @@
+package tfo
+
+import (
+	"math"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpiOptSYNData is set in tcpi_options when the SYN-ACK acknowledged data in the SYN.
+const tcpiOptSYNData = 32
+
+// getTCPInfo returns the TCP_INFO of c.
+func getTCPInfo(c *net.TCPConn) (info *unix.TCPInfo, err error) {
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	if cerr := rawConn.Control(func(fd uintptr) {
+		info, err = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); cerr != nil {
+		return nil, cerr
+	}
+	return info, err
+}
+
+func synDataAcked(c *net.TCPConn) bool {
+	info, err := getTCPInfo(c)
+	if err != nil {
+		return false
+	}
+	return info.Options&tcpiOptSYNData != 0
+}
+
+func acceptInfo(c *net.TCPConn) (info AcceptInfo) {
+	info.QueuedBytes = -1
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return
+	}
+	_ = rawConn.Control(func(fd uintptr) {
+		if tcpInfo, err := unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO); err == nil {
+			info.SYNData = tcpInfo.Options&tcpiOptSYNData != 0
+		}
+		// MPTCP sockets report INT_MAX when the queue length is not known.
+		if n, err := unix.IoctlGetInt(int(fd), unix.SIOCINQ); err == nil && n != math.MaxInt32 {
+			info.QueuedBytes = n
+		}
+	})
+	return
+}
+
+func peekReceived(c *net.TCPConn, b []byte) (n int) {
+	if len(b) == 0 {
+		return 0
+	}
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	_ = rawConn.Control(func(fd uintptr) {
+		for {
+			n, _, err = unix.Recvfrom(int(fd), b, unix.MSG_PEEK|unix.MSG_DONTWAIT)
+			if err != unix.EINTR {
+				break
+			}
+		}
+	})
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package tfo
+
+import "net"
+
+func synDataAcked(_ *net.TCPConn) bool {
+	return false
+}
+
+func acceptInfo(_ *net.TCPConn) AcceptInfo {
+	return AcceptInfo{QueuedBytes: -1}
+}
+
+func peekReceived(_ *net.TCPConn, _ []byte) int {
+	return 0
+}
@@ -0,0 +1,304 @@
+package tfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// CoalesceConfig controls how a [LazyConn] coalesces writes before initiating the handshake.
+type CoalesceConfig struct {
+	// MaxBytes is the number of buffered bytes that initiates the handshake.
+	// If zero or negative, the handshake is only initiated by MaxDelay, reads, or CloseWrite.
+	MaxBytes int
+
+	// MaxDelay is the maximum time to buffer writes after the first write.
+	// If zero or negative, writes are not coalesced, and the first write initiates the handshake.
+	MaxDelay time.Duration
+}
+
+// LazyConn is a [net.Conn] that defers connecting until the first write,
+// so that the written data can be sent in the SYN.
+//
+// Errors from the deferred dial are returned by the first Read or Write call
+// that observes them. When writes are coalesced, a Write call may return success
+// for data that is later discarded because of a failed dial.
+type LazyConn struct {
+	d        Dialer
+	ctx      context.Context
+	cancel   context.CancelFunc
+	network  string
+	address  string
+	coalesce CoalesceConfig
+
+	// done is closed when the dial attempt finishes.
+	// conn and err must not be accessed before done is closed.
+	done chan struct{}
+	conn net.Conn
+	err  error
+
+	mu            sync.Mutex
+	buf           []byte
+	timer         *time.Timer
+	started       bool
+	closed        bool
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// DialLazy returns a [*LazyConn] that dials the address on the named network
+// when the first Write, Read, or CloseWrite call is made.
+// The payload of the first write, or the coalesced payload as configured by coalesce,
+// is sent in the SYN whenever possible, as if passed to [Dialer.DialContext].
+//
+// The provided context is used for the deferred dial. It must remain valid until
+// the connection is established. Once successfully connected, any expiration of
+// the context will not affect the connection.
+//
+// The network must be a TCP network name.
+func (d *Dialer) DialLazy(ctx context.Context, network, address string, coalesce CoalesceConfig) (*LazyConn, error) {
+	if ctx == nil {
+		panic("nil context")
+	}
+	if !networkIsTCP(network) {
+		return nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: net.UnknownNetworkError(network)}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &LazyConn{
+		d:        *d,
+		ctx:      ctx,
+		cancel:   cancel,
+		network:  network,
+		address:  address,
+		coalesce: coalesce,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// connectLocked dials with b as the payload, and records the result.
+// It must be called with c.mu held, and c.started unset.
+// c.mu is released during the dial, and held again when it returns.
+func (c *LazyConn) connectLocked(b []byte) {
+	c.started = true
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.buf = nil
+
+	// Bound the dial by the deadlines, so that a Read or Write does not block on it indefinitely.
+	ctx := c.ctx
+	if deadline := minNonzeroTime(c.readDeadline, c.writeDeadline); !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	c.mu.Unlock()
+
+	conn, err := c.d.DialContext(ctx, c.network, c.address, b)
+	c.cancel()
+
+	c.mu.Lock()
+	if err == nil {
+		if c.closed {
+			conn.Close()
+			conn, err = nil, net.ErrClosed
+		} else {
+			if !c.readDeadline.IsZero() {
+				_ = conn.SetReadDeadline(c.readDeadline)
+			}
+			if !c.writeDeadline.IsZero() {
+				_ = conn.SetWriteDeadline(c.writeDeadline)
+			}
+		}
+	}
+	c.conn, c.err = conn, err
+	close(c.done)
+}
+
+// flush initiates the handshake with the buffered payload, if not already started.
+func (c *LazyConn) flush() {
+	c.mu.Lock()
+	if !c.started && !c.closed {
+		c.connectLocked(c.buf)
+	}
+	c.mu.Unlock()
+}
+
+// wait initiates the handshake if not already started, and waits for the dial to finish.
+func (c *LazyConn) wait() (net.Conn, error) {
+	c.flush()
+	<-c.done
+	return c.conn, c.err
+}
+
+// established returns the underlying connection if the dial has finished successfully.
+func (c *LazyConn) established() net.Conn {
+	select {
+	case <-c.done:
+		return c.conn
+	default:
+		return nil
+	}
+}
+
+func (c *LazyConn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: c.network, Source: nil, Addr: nil, Err: err}
+}
+
+// Read implements [net.Conn.Read].
+// If the handshake has not been initiated, Read initiates it with any buffered payload.
+func (c *LazyConn) Read(b []byte) (int, error) {
+	conn, err := c.wait()
+	if err != nil {
+		return 0, err
+	}
+	return conn.Read(b)
+}
+
+// Write implements [net.Conn.Write].
+func (c *LazyConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return 0, c.opError("write", net.ErrClosed)
+	}
+	if !c.started {
+		if len(b) == 0 {
+			c.mu.Unlock()
+			return 0, nil
+		}
+
+		n := len(b)
+		if c.coalesce.MaxDelay > 0 {
+			c.buf = append(c.buf, b...)
+			if c.coalesce.MaxBytes <= 0 || len(c.buf) < c.coalesce.MaxBytes {
+				if c.timer == nil {
+					c.timer = time.AfterFunc(c.coalesce.MaxDelay, c.flush)
+				}
+				c.mu.Unlock()
+				return n, nil
+			}
+			b = c.buf
+		}
+
+		c.connectLocked(b)
+		err := c.err
+		c.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	c.mu.Unlock()
+
+	<-c.done
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.conn.Write(b)
+}
+
+// Close implements [net.Conn.Close].
+// If the dial is in progress, it is canceled.
+func (c *LazyConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return c.opError("close", net.ErrClosed)
+	}
+	c.closed = true
+	c.cancel()
+	if !c.started {
+		c.started = true
+		if c.timer != nil {
+			c.timer.Stop()
+			c.timer = nil
+		}
+		c.buf = nil
+		c.err = c.opError("dial", net.ErrClosed)
+		close(c.done)
+	}
+	c.mu.Unlock()
+
+	if conn := c.established(); conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// CloseWrite shuts down the writing side of the connection.
+// If the handshake has not been initiated, CloseWrite initiates it with any buffered payload.
+func (c *LazyConn) CloseWrite() error {
+	conn, err := c.wait()
+	if err != nil {
+		return err
+	}
+	cw, ok := conn.(interface{ CloseWrite() error })
+	if !ok {
+		return c.opError("close", errors.ErrUnsupported)
+	}
+	return cw.CloseWrite()
+}
+
+// LocalAddr implements [net.Conn.LocalAddr].
+// It returns nil if the connection has not been established.
+func (c *LazyConn) LocalAddr() net.Addr {
+	if conn := c.established(); conn != nil {
+		return conn.LocalAddr()
+	}
+	return nil
+}
+
+// RemoteAddr implements [net.Conn.RemoteAddr].
+// It returns nil if the connection has not been established.
+func (c *LazyConn) RemoteAddr() net.Addr {
+	if conn := c.established(); conn != nil {
+		return conn.RemoteAddr()
+	}
+	return nil
+}
+
+// SetDeadline implements [net.Conn.SetDeadline].
+// Deadlines set before the handshake is initiated also bound the dial, which fails
+// when the earliest of them passes. Deadlines set before the connection is established
+// are applied once it is.
+func (c *LazyConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn := c.established(); conn != nil {
+		return conn.SetDeadline(t)
+	}
+	c.readDeadline = t
+	c.writeDeadline = t
+	return nil
+}
+
+// SetReadDeadline implements [net.Conn.SetReadDeadline].
+func (c *LazyConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn := c.established(); conn != nil {
+		return conn.SetReadDeadline(t)
+	}
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline implements [net.Conn.SetWriteDeadline].
+func (c *LazyConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn := c.established(); conn != nil {
+		return conn.SetWriteDeadline(t)
+	}
+	c.writeDeadline = t
+	return nil
+}
+
+// NetConn returns the underlying connection, or nil if the connection has not been established.
+func (c *LazyConn) NetConn() net.Conn {
+	return c.established()
+}
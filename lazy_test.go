@@ -0,0 +1,166 @@
+package tfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func testLazyConn(t *testing.T, coalesce CoalesceConfig, writes ...[]byte) {
+	lc := ListenConfig{Fallback: true}
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lntcp := ln.(*net.TCPListener)
+	defer lntcp.Close()
+
+	var payload []byte
+	for _, b := range writes {
+		payload = append(payload, b...)
+	}
+
+	ctrlCh := make(chan struct{})
+	go func() {
+		conn, err := lntcp.AcceptTCP()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		readUntilEOF(conn, payload, t)
+		write(conn, worldhello, t)
+		conn.CloseWrite()
+		close(ctrlCh)
+	}()
+
+	d := Dialer{Fallback: true}
+	c, err := d.DialLazy(t.Context(), "tcp", ln.Addr().String(), coalesce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if c.NetConn() != nil {
+		t.Error("NetConn() is not nil before the first write")
+	}
+	if addr := c.RemoteAddr(); addr != nil {
+		t.Errorf("RemoteAddr() = %v, want nil before the first write", addr)
+	}
+
+	for _, b := range writes {
+		write(c, b, t)
+	}
+	if err = c.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	readUntilEOF(c, worldhello, t)
+	<-ctrlCh
+
+	if raddr := c.RemoteAddr(); raddr.String() != ln.Addr().String() {
+		t.Errorf("RemoteAddr() = %v, want %v", raddr, ln.Addr())
+	}
+
+	info, ok := ConnTFOInfo(c)
+	if !ok {
+		t.Fatal("ConnTFOInfo returned false")
+	}
+	t.Logf("info: %+v", info)
+	if info.SYNBytes > len(payload) {
+		t.Errorf("info.SYNBytes = %d, want <= %d", info.SYNBytes, len(payload))
+	}
+}
+
+func TestLazyConn(t *testing.T) {
+	t.Run("NoCoalesce", func(t *testing.T) {
+		testLazyConn(t, CoalesceConfig{}, hello, world)
+	})
+	t.Run("CoalesceMaxBytes", func(t *testing.T) {
+		testLazyConn(t, CoalesceConfig{MaxBytes: len(helloworld), MaxDelay: time.Hour}, hello, world)
+	})
+	t.Run("CoalesceMaxDelay", func(t *testing.T) {
+		testLazyConn(t, CoalesceConfig{MaxDelay: time.Millisecond}, hello, world)
+	})
+	t.Run("CoalesceCloseWrite", func(t *testing.T) {
+		testLazyConn(t, CoalesceConfig{MaxDelay: time.Hour}, hello, world)
+	})
+}
+
+// TestLazyConnDialError ensures that errors from the deferred dial are returned by Write or Read.
+//
+// With TCP_FASTOPEN_CONNECT and a cached cookie, the first write succeeds as soon as
+// the SYN is sent, and the connection error is only observed by the next Read.
+func TestLazyConnDialError(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	d := Dialer{Fallback: true}
+	c, err := d.DialLazy(t.Context(), "tcp", address, CoalesceConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err = c.Write(hello); err == nil {
+		_, err = c.Read(make([]byte, 1))
+	}
+	if err == nil {
+		t.Fatal("Write and Read succeeded, want connection refused")
+	}
+	t.Log("Got expected error:", err)
+}
+
+// TestLazyConnClose ensures that a closed [LazyConn] never dials.
+func TestLazyConnClose(t *testing.T) {
+	var d Dialer
+	c, err := d.DialLazy(t.Context(), "tcp", "[::1]:1", CoalesceConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Write(hello); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Write error = %v, want %v", err, net.ErrClosed)
+	}
+	if _, err = c.Read(make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Read error = %v, want %v", err, net.ErrClosed)
+	}
+	if err = c.Close(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Close error = %v, want %v", err, net.ErrClosed)
+	}
+
+	if _, err = d.DialLazy(t.Context(), "udp", "[::1]:1", CoalesceConfig{}); err == nil {
+		t.Error("DialLazy succeeded with a UDP network")
+	}
+}
+
+// TestLazyConnReadDeadline ensures that a read deadline set before the handshake bounds the dial.
+func TestLazyConnReadDeadline(t *testing.T) {
+	var d Dialer
+	d.ControlContext = func(ctx context.Context, _, _ string, _ syscall.RawConn) error {
+		// Block the dial until it is canceled.
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	c, err := d.DialLazy(t.Context(), "tcp", "[::1]:1", CoalesceConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err = c.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Read(make([]byte, 1)); err == nil {
+		t.Error("Read succeeded with a blocked dial")
+	}
+}
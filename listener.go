@@ -0,0 +1,111 @@
+package tfo
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// AcceptInfo reports TFO metadata of an accepted connection.
+type AcceptInfo struct {
+	// SYNData reports whether the SYN carried data that was accepted by the kernel.
+	SYNData bool
+
+	// QueuedBytes is the number of bytes in the receive queue at accept time.
+	// For connections with SYN data, this includes the data in the SYN.
+	// It is -1 if unavailable, such as on platforms other than Linux, or for MPTCP connections.
+	QueuedBytes int
+}
+
+// ListenerStats is a snapshot of the counters of a [TCPListener].
+type ListenerStats struct {
+	// TFOAccepts is the number of accepted connections whose SYN carried data.
+	TFOAccepts uint64
+
+	// PlainAccepts is the number of accepted connections whose SYN did not carry data.
+	PlainAccepts uint64
+}
+
+// TCPListener wraps [*net.TCPListener] to report TFO metadata of accepted connections.
+//
+// TFO metadata is only available on Linux, where it is read from TCP_INFO and
+// the receive queue (SIOCINQ). On other platforms, every accepted connection
+// is reported as without SYN data.
+type TCPListener struct {
+	*net.TCPListener
+
+	tfoAccepts   atomic.Uint64
+	plainAccepts atomic.Uint64
+}
+
+// NewTCPListener returns a [*TCPListener] that wraps ln.
+func NewTCPListener(ln *net.TCPListener) *TCPListener {
+	return &TCPListener{TCPListener: ln}
+}
+
+// ListenTCP is like [ListenConfig.Listen] but returns a [*TCPListener].
+func (lc *ListenConfig) ListenTCP(ctx context.Context, network, address string) (*TCPListener, error) {
+	if !networkIsTCP(network) {
+		return nil, &net.OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: net.UnknownNetworkError(network)}
+	}
+	ln, err := lc.Listen(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewTCPListener(ln.(*net.TCPListener)), nil
+}
+
+// AcceptTFO accepts the next incoming connection, and returns its TFO metadata.
+func (l *TCPListener) AcceptTFO() (*net.TCPConn, AcceptInfo, error) {
+	c, err := l.TCPListener.AcceptTCP()
+	if err != nil {
+		return nil, AcceptInfo{}, err
+	}
+	info := acceptInfo(c) // info_linux.go, info_stub.go
+	if info.SYNData {
+		l.tfoAccepts.Add(1)
+	} else {
+		l.plainAccepts.Add(1)
+	}
+	recordAccept(info)
+	return c, info, nil
+}
+
+// AcceptPeek is like [TCPListener.AcceptTFO], and also copies into b the data
+// that is readable from the connection without blocking, such as the data in the SYN.
+// It returns the number of bytes copied. The data is not consumed,
+// and is returned again by subsequent reads from the connection.
+//
+// Combine it with [ListenConfig.DeferAccept] to only accept connections once data has arrived.
+//
+// Peeking is only supported on Linux. On other platforms, n is always 0.
+func (l *TCPListener) AcceptPeek(b []byte) (c *net.TCPConn, n int, info AcceptInfo, err error) {
+	c, info, err = l.AcceptTFO()
+	if err != nil {
+		return nil, 0, AcceptInfo{}, err
+	}
+	return c, peekReceived(c, b), info, nil // info_linux.go, info_stub.go
+}
+
+// AcceptTCP is like [net.TCPListener.AcceptTCP], and updates the counters.
+func (l *TCPListener) AcceptTCP() (*net.TCPConn, error) {
+	c, _, err := l.AcceptTFO()
+	return c, err
+}
+
+// Accept implements [net.Listener.Accept], and updates the counters.
+func (l *TCPListener) Accept() (net.Conn, error) {
+	c, _, err := l.AcceptTFO()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Stats returns a snapshot of the listener's counters.
+func (l *TCPListener) Stats() ListenerStats {
+	return ListenerStats{
+		TFOAccepts:   l.tfoAccepts.Load(),
+		PlainAccepts: l.plainAccepts.Load(),
+	}
+}
@@ -0,0 +1,70 @@
+package tfo
+
+import (
+	"net"
+	"runtime"
+	"testing"
+)
+
+func testListenerAcceptTFO(t *testing.T, lc ListenConfig, d Dialer) {
+	ln, err := lc.ListenTCP(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	const attempts = 2
+	var tfoAccepts uint64
+
+	for range attempts {
+		c, err := d.DialContext(t.Context(), "tcp", ln.Addr().String(), hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sc, info, err := ln.AcceptTFO()
+		if err != nil {
+			c.Close()
+			t.Fatal(err)
+		}
+		t.Logf("info: %+v", info)
+
+		if info.SYNData {
+			tfoAccepts++
+			if !lc.TFO() || !d.TFO() {
+				t.Error("info.SYNData = true without TFO")
+			}
+			if runtime.GOOS == "linux" && info.QueuedBytes != -1 && info.QueuedBytes != len(hello) {
+				t.Errorf("info.QueuedBytes = %d, want %d", info.QueuedBytes, len(hello))
+			}
+		}
+		if info.QueuedBytes < -1 || info.QueuedBytes > len(hello) {
+			t.Errorf("info.QueuedBytes = %d, want [-1, %d]", info.QueuedBytes, len(hello))
+		}
+
+		c.(*net.TCPConn).CloseWrite()
+		readUntilEOF(sc, hello, t)
+		sc.Close()
+		c.Close()
+	}
+
+	stats := ln.Stats()
+	if stats.TFOAccepts != tfoAccepts || stats.PlainAccepts != attempts-tfoAccepts {
+		t.Errorf("Stats() = %+v, want %d TFO accepts and %d plain accepts", stats, tfoAccepts, attempts-tfoAccepts)
+	}
+}
+
+// TestListenerAcceptTFO ensures that [TCPListener] reports accepted connections consistently.
+func TestListenerAcceptTFO(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testListenerAcceptTFO)
+	}
+}
+
+func TestListenConfigListenTCPNonTCP(t *testing.T) {
+	var lc ListenConfig
+	if ln, err := lc.ListenTCP(t.Context(), "udp", "[::1]:"); err == nil {
+		ln.Close()
+		t.Error("ListenTCP succeeded with a UDP network")
+	}
+}
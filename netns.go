@@ -0,0 +1,80 @@
+package tfo
+
+// NetNS is a handle to a Linux network namespace, in which [Dialer] and [ListenConfig]
+// create their sockets when their NetNS field is set.
+//
+// Sockets are created on a locked OS thread that temporarily joins the namespace with setns(2),
+// which requires CAP_SYS_ADMIN in the user namespaces of both the caller and the target.
+// Names are resolved by the resolver, which may create its sockets outside the namespace.
+// Dual-stack racing is disabled in the namespace, and addresses are tried sequentially.
+//
+// Each namespace has its own tcp_fastopen sysctl. When Fallback is set, dial and listen calls
+// read the sysctl of the namespace, and proceed without TFO if it is disabled there.
+// As lack of TFO support observed in one namespace does not apply to others,
+// use a separate [SupportCache] for each namespace.
+type NetNS struct {
+	fd    int
+	owned bool
+}
+
+// OpenNetNS opens the network namespace file at path,
+// such as /var/run/netns/NAME or /proc/PID/ns/net.
+// The returned handle must be closed when no longer in use.
+//
+// This is only supported on Linux.
+func OpenNetNS(path string) (*NetNS, error) {
+	return openNetNS(path) // netns_linux.go, netns_stub.go
+}
+
+// NetNSFromFD returns a handle to the network namespace referred to by fd.
+// The caller retains ownership of fd, which must remain open while the handle is in use.
+func NetNSFromFD(fd int) *NetNS {
+	return &NetNS{fd: fd}
+}
+
+// FD returns the file descriptor of the namespace.
+func (ns *NetNS) FD() int {
+	return ns.fd
+}
+
+// Close closes the namespace file, if it was opened by [OpenNetNS].
+func (ns *NetNS) Close() error {
+	if !ns.owned {
+		return nil
+	}
+	ns.owned = false
+	return closeNetNS(ns.fd) // netns_linux.go, netns_stub.go
+}
+
+// TFOSysctl returns the value of the net.ipv4.tcp_fastopen sysctl in the namespace.
+//
+// This is only supported on Linux.
+func (ns *NetNS) TFOSysctl() (int, error) {
+	return inNetNS(ns, readNetNSTFOSysctl) // netns_linux.go, netns_stub.go
+}
+
+// netNSCopy returns a copy of d for dialing on a thread that has joined d.NetNS.
+func (d *Dialer) netNSCopy() *Dialer {
+	nd := *d
+	nd.NetNS = nil
+	// Goroutines started for dual-stack racing would not run in the namespace.
+	nd.FallbackDelay = -1
+	if nd.Fallback && !nd.DisableTFO {
+		if v, err := readNetNSTFOSysctl(); err == nil && v&tfoClientEnable == 0 {
+			nd.DisableTFO = true
+		}
+	}
+	return &nd
+}
+
+// netNSCopy returns a copy of lc for listening on a thread that has joined lc.NetNS.
+func (lc *ListenConfig) netNSCopy() *ListenConfig {
+	llc := *lc
+	llc.NetNS = nil
+	if llc.Fallback && !llc.tfoDisabled() {
+		if v, err := readNetNSTFOSysctl(); err == nil && v&tfoServerEnable == 0 {
+			llc.DisableTFO = true
+		}
+	}
+	return &llc
+}
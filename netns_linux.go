@@ -0,0 +1,74 @@
+package tfo
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+func openNetNS(path string) (*NetNS, error) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return &NetNS{fd: fd, owned: true}, nil
+}
+
+func closeNetNS(fd int) error {
+	return unix.Close(fd)
+}
+
+// inNetNS calls fn on a locked OS thread that has joined ns.
+//
+// fn runs in a new goroutine. Goroutines started by fn do not run in ns.
+func inNetNS[T any](ns *NetNS, fn func() (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+	ch := make(chan result, 1)
+
+	go func() {
+		runtime.LockOSThread()
+
+		orig, err := unix.Open("/proc/thread-self/ns/net", unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			runtime.UnlockOSThread()
+			ch <- result{err: &os.PathError{Op: "open", Path: "/proc/thread-self/ns/net", Err: err}}
+			return
+		}
+		defer unix.Close(orig)
+
+		if err = unix.Setns(ns.fd, unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			ch <- result{err: os.NewSyscallError("setns", err)}
+			return
+		}
+
+		var res result
+		res.v, res.err = fn()
+
+		// If the thread cannot return to its original namespace, keep it locked,
+		// so that it exits with the goroutine, instead of being reused.
+		if unix.Setns(orig, unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+		ch <- res
+	}()
+
+	res := <-ch
+	return res.v, res.err
+}
+
+// readNetNSTFOSysctl reads the tcp_fastopen sysctl of the network namespace of the calling thread.
+func readNetNSTFOSysctl() (int, error) {
+	// /proc/sys/net reflects the network namespace of the task that opens it.
+	b, err := os.ReadFile("/proc/sys/net/ipv4/tcp_fastopen")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
@@ -0,0 +1,200 @@
+package tfo
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// newTestNetNS starts a process in a new user and network namespace,
+// and returns a handle to its network namespace, with the loopback interface up.
+func newTestNetNS(t *testing.T) *NetNS {
+	cmd := exec.Command("sleep", "3600")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skip("cannot create user and network namespace:", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	ns, err := OpenNetNS("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/ns/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ns.Close()
+	})
+
+	if _, err = inNetNS(ns, func() (struct{}, error) {
+		fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+		if err != nil {
+			return struct{}{}, err
+		}
+		defer unix.Close(fd)
+		ifr, err := unix.NewIfreq("lo")
+		if err != nil {
+			return struct{}{}, err
+		}
+		if err = unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+			return struct{}{}, err
+		}
+		ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+		return struct{}{}, unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+	}); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			t.Skip("cannot join network namespace:", err)
+		}
+		t.Fatal(err)
+	}
+	return ns
+}
+
+// netNSIno returns the inode number of ns.
+func netNSIno(t *testing.T, ns *NetNS) uint64 {
+	t.Helper()
+	var st unix.Stat_t
+	if err := unix.Fstat(ns.fd, &st); err != nil {
+		t.Fatal(err)
+	}
+	return st.Ino
+}
+
+// socketNetNSIno returns the inode number of the network namespace of the socket sc.
+func socketNetNSIno(t *testing.T, sc syscall.Conn) uint64 {
+	t.Helper()
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nsfd int
+	if cerr := rawConn.Control(func(fd uintptr) {
+		nsfd, err = unix.IoctlRetInt(int(fd), unix.SIOCGSKNS)
+	}); cerr != nil {
+		t.Fatal(cerr)
+	}
+	if err != nil {
+		t.Fatal("ioctl(SIOCGSKNS):", err)
+	}
+	defer unix.Close(nsfd)
+	var st unix.Stat_t
+	if err = unix.Fstat(nsfd, &st); err != nil {
+		t.Fatal(err)
+	}
+	return st.Ino
+}
+
+// setTestNetNSTFOSysctl sets the tcp_fastopen sysctl in ns.
+func setTestNetNSTFOSysctl(t *testing.T, ns *NetNS, v int) {
+	if _, err := inNetNS(ns, func() (struct{}, error) {
+		return struct{}{}, os.WriteFile("/proc/sys/net/ipv4/tcp_fastopen", []byte(strconv.Itoa(v)), 0)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ns.TFOSysctl(); err != nil || got != v {
+		t.Fatalf("TFOSysctl() = %d, %v, want %d", got, err, v)
+	}
+}
+
+func TestNetNS(t *testing.T) {
+	ns := newTestNetNS(t)
+	setTestNetNSTFOSysctl(t, ns, tfoClientEnable|tfoServerEnable)
+
+	lc := ListenConfig{NetNS: ns}
+	ln, err := lc.ListenTCP(t.Context(), "tcp4", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	address := ln.Addr().String()
+
+	// The listener must be created in the namespace.
+	nsIno := netNSIno(t, ns)
+	if ino := socketNetNSIno(t, ln); ino != nsIno {
+		t.Errorf("listener network namespace inode = %d, want %d", ino, nsIno)
+	}
+
+	sendto := new(SupportCache)
+	sendto.storeDial(dialTFOSupportLinuxSendto)
+
+	for _, c := range []struct {
+		name     string
+		dialer   Dialer
+		wantPath DialPath
+	}{
+		{"ConnectOption", Dialer{NetNS: ns}, DialPathConnectOption},
+		{"Sendto", Dialer{NetNS: ns, Fallback: true, SupportCache: sendto}, DialPathSendmsg},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			// The first connection may only obtain a TFO cookie.
+			var info AcceptInfo
+			for range 2 {
+				conn, err := c.dialer.DialContext(t.Context(), "tcp4", address, hello)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tfoInfo, _ := ConnTFOInfo(conn); tfoInfo.Path != c.wantPath {
+					t.Errorf("Path = %v, want %v", tfoInfo.Path, c.wantPath)
+				}
+				if ino := socketNetNSIno(t, conn.(*net.TCPConn)); ino != nsIno {
+					t.Errorf("connection network namespace inode = %d, want %d", ino, nsIno)
+				}
+				var sc *net.TCPConn
+				sc, info, err = ln.AcceptTFO()
+				if err != nil {
+					conn.Close()
+					t.Fatal(err)
+				}
+				conn.(*net.TCPConn).CloseWrite()
+				readUntilEOF(sc, hello, t)
+				sc.Close()
+				conn.Close()
+			}
+			if !info.SYNData {
+				t.Error("connection in namespace did not carry data in SYN")
+			}
+		})
+	}
+}
+
+// TestNetNSSysctlFallback ensures that the tcp_fastopen sysctl of the namespace
+// is respected when Fallback is set.
+func TestNetNSSysctlFallback(t *testing.T) {
+	ns := newTestNetNS(t)
+	setTestNetNSTFOSysctl(t, ns, tfoClientEnable)
+
+	lc := ListenConfig{NetNS: ns, Fallback: true}
+	ln, err := lc.ListenTCP(t.Context(), "tcp4", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	rawConn, err := ln.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var qlen int
+	if cerr := rawConn.Control(func(fd uintptr) {
+		qlen, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN)
+	}); cerr != nil {
+		t.Fatal(cerr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qlen != 0 {
+		t.Errorf("TCP_FASTOPEN = %d on listener in namespace with server TFO disabled, want 0", qlen)
+	}
+}
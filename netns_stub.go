@@ -0,0 +1,20 @@
+//go:build !linux
+
+package tfo
+
+func openNetNS(_ string) (*NetNS, error) {
+	return nil, ErrPlatformUnsupported
+}
+
+func closeNetNS(_ int) error {
+	return ErrPlatformUnsupported
+}
+
+func inNetNS[T any](_ *NetNS, _ func() (T, error)) (T, error) {
+	var zero T
+	return zero, ErrPlatformUnsupported
+}
+
+func readNetNSTFOSysctl() (int, error) {
+	return 0, ErrPlatformUnsupported
+}
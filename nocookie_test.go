@@ -0,0 +1,70 @@
+package tfo
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+	"net/netip"
+	"runtime"
+	"testing"
+)
+
+func TestNoCookie(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		lc := ListenConfig{NoCookie: true}
+		if ln, err := lc.Listen(t.Context(), "tcp", "[::1]:"); !errors.Is(err, errors.ErrUnsupported) {
+			if err == nil {
+				ln.Close()
+			}
+			t.Errorf("Listen error = %v, want %v", err, errors.ErrUnsupported)
+		}
+
+		lc.Fallback = true
+		ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln.Close()
+		return
+	}
+
+	r, err := Probe(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.ClientEnabled || !r.ServerEnabled || !r.NoCookieOption {
+		t.Skip("cookieless TFO is not available")
+	}
+
+	// Use a random loopback address, so that the client has no cached cookie for it.
+	var b [3]byte
+	rand.Read(b[:])
+	address := netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, b[0], b[1], b[2] | 1}), 0).String()
+
+	lc := ListenConfig{NoCookie: true}
+	ln, err := lc.ListenTCP(t.Context(), "tcp4", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	d := Dialer{NoCookie: true}
+	c, err := d.DialContext(t.Context(), "tcp", ln.Addr().String(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	sc, info, err := ln.AcceptTFO()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	if !info.SYNData {
+		t.Error("first connection did not carry data in SYN")
+	}
+
+	c.(*net.TCPConn).CloseWrite()
+	readUntilEOF(sc, hello, t)
+}
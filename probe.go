@@ -0,0 +1,73 @@
+package tfo
+
+import (
+	"context"
+)
+
+// Bits of the net.ipv4.tcp_fastopen sysctl on Linux.
+const (
+	tfoClientEnable        = 0x1   // TFO_CLIENT_ENABLE
+	tfoServerEnable        = 0x2   // TFO_SERVER_ENABLE
+	tfoClientNoCookie      = 0x4   // TFO_CLIENT_NO_COOKIE
+	tfoServerCookieNotReqd = 0x200 // TFO_SERVER_COOKIE_NOT_REQD
+	tfoServerWOSockopt1    = 0x400 // TFO_SERVER_WO_SOCKOPT1
+)
+
+// ProbeResult describes the TFO features supported by the host.
+type ProbeResult struct {
+	// Sysctl is the value of net.ipv4.tcp_fastopen, or -1 if it could not be read.
+	Sysctl int
+
+	// ClientEnabled reports whether TFO is enabled for outgoing connections (TFO_CLIENT_ENABLE).
+	ClientEnabled bool
+
+	// ServerEnabled reports whether TFO is enabled for listeners (TFO_SERVER_ENABLE).
+	ServerEnabled bool
+
+	// ClientNoCookie reports whether outgoing connections send data in SYN
+	// without a cookie system-wide (TFO_CLIENT_NO_COOKIE).
+	ClientNoCookie bool
+
+	// ServerNoCookie reports whether listeners accept data in SYN
+	// without a cookie system-wide (TFO_SERVER_COOKIE_NOT_REQD).
+	ServerNoCookie bool
+
+	// ServerWithoutSockopt reports whether TFO is enabled on all listeners,
+	// without setting TCP_FASTOPEN (TFO_SERVER_WO_SOCKOPT1).
+	ServerWithoutSockopt bool
+
+	// ConnectOption reports whether the TCP_FASTOPEN_CONNECT socket option is available (Linux 4.11+).
+	// If false, the dialer can only use sendto(MSG_FASTOPEN).
+	ConnectOption bool
+
+	// NoCookieOption reports whether the TCP_FASTOPEN_NO_COOKIE socket option is available (Linux 4.15+).
+	NoCookieOption bool
+
+	// MultiKey reports whether TCP_FASTOPEN_KEY accepts a backup key (Linux 5.4+).
+	MultiKey bool
+
+	// ConnectOptionRoundTrip reports whether a loopback connection dialed with TCP_FASTOPEN_CONNECT
+	// carried data in the SYN, which was accepted by the listener.
+	ConnectOptionRoundTrip bool
+
+	// SendtoRoundTrip reports whether a loopback connection dialed with sendto(MSG_FASTOPEN)
+	// carried data in the SYN, which was accepted by the listener.
+	SendtoRoundTrip bool
+
+	// RoundTripErr is the first error encountered by the loopback round trips, if any.
+	RoundTripErr error
+}
+
+// Probe reports the TFO features supported by the host.
+//
+// Unlike [Dialer.TFO] and [ListenConfig.TFO], which only report whether TFO will be attempted,
+// Probe inspects the system configuration, tests the relevant socket options, and dials
+// loopback connections to check whether data is actually sent in the SYN.
+// The loopback round trips update the kernel's TFO cookie cache for the loopback address,
+// but not [DefaultSupportCache] or the package-wide counters reported by [ReadStats].
+//
+// This is only supported on Linux. The returned error is non-nil if the platform is
+// not supported, or if ctx is done before the probe completes.
+func Probe(ctx context.Context) (ProbeResult, error) {
+	return probe(ctx) // probe_linux.go, probe_stub.go
+}
@@ -0,0 +1,115 @@
+package tfo
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// probePayload is the data sent in SYN by the loopback round trips.
+var probePayload = []byte("tfo-go probe")
+
+func probe(ctx context.Context) (r ProbeResult, err error) {
+	r.Sysctl = readTFOSysctl()
+	if r.Sysctl >= 0 {
+		r.ClientEnabled = r.Sysctl&tfoClientEnable != 0
+		r.ServerEnabled = r.Sysctl&tfoServerEnable != 0
+		r.ClientNoCookie = r.Sysctl&tfoClientNoCookie != 0
+		r.ServerNoCookie = r.Sysctl&tfoServerCookieNotReqd != 0
+		r.ServerWithoutSockopt = r.Sysctl&tfoServerWOSockopt1 != 0
+	}
+
+	r.ConnectOption = probeSockopt(func(fd int) error {
+		return setTFODialer(uintptr(fd))
+	})
+	r.NoCookieOption = probeSockopt(func(fd int) error {
+		return unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_FASTOPEN_NO_COOKIE, 1)
+	})
+	r.MultiKey = probeSockopt(func(fd int) error {
+		return setTFOKeys(uintptr(fd), make([]TFOKey, maxTFOKeys))
+	})
+
+	// Keep the round trips out of the global support state and the package-wide counters.
+	ctx = withoutStats(ctx)
+	supportCache := new(SupportCache)
+	d := Dialer{SupportCache: supportCache}
+	if r.ConnectOption {
+		r.ConnectOptionRoundTrip, r.RoundTripErr = probeRoundTrip(ctx, supportCache, func(ctx context.Context, address string) (*net.TCPConn, error) {
+			return d.dialTFO(ctx, "tcp", address, [][]byte{probePayload})
+		})
+	}
+	var sendtoErr error
+	r.SendtoRoundTrip, sendtoErr = probeRoundTrip(ctx, supportCache, func(ctx context.Context, address string) (*net.TCPConn, error) {
+		return d.dialTFOFromSocket(ctx, "tcp", address, [][]byte{probePayload})
+	})
+	if r.RoundTripErr == nil {
+		r.RoundTripErr = sendtoErr
+	}
+
+	return r, ctx.Err()
+}
+
+// readTFOSysctl returns the value of net.ipv4.tcp_fastopen, or -1 if it could not be read.
+func readTFOSysctl() int {
+	b, err := os.ReadFile("/proc/sys/net/ipv4/tcp_fastopen")
+	if err != nil {
+		return -1
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+// probeSockopt returns whether fn succeeds on a new TCP socket.
+func probeSockopt(fn func(fd int) error) bool {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, unix.IPPROTO_TCP)
+	if err != nil {
+		return false
+	}
+	defer unix.Close(fd)
+	return fn(fd) == nil
+}
+
+// probeRoundTrip dials a TFO listener on the loopback address with dial,
+// and returns whether the listener accepted data in the SYN.
+// The first connection may only obtain a cookie, so a second connection is made if needed.
+func probeRoundTrip(ctx context.Context, supportCache *SupportCache, dial func(ctx context.Context, address string) (*net.TCPConn, error)) (bool, error) {
+	lc := ListenConfig{SupportCache: supportCache}
+	ln, err := lc.ListenTCP(ctx, "tcp4", "127.0.0.1:0")
+	if err != nil {
+		return false, err
+	}
+	defer ln.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = ln.SetDeadline(aLongTimeAgo)
+	})
+	defer stop()
+
+	for range 2 {
+		c, err := dial(ctx, ln.Addr().String())
+		if err != nil {
+			return false, err
+		}
+
+		// Bypass [TCPListener.AcceptTFO] to keep the accept out of the package-wide counters.
+		sc, err := ln.TCPListener.AcceptTCP()
+		c.Close()
+		if err != nil {
+			return false, err
+		}
+		info := acceptInfo(sc)
+		sc.Close()
+
+		if info.SYNData {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package tfo
+
+import "context"
+
+func probe(_ context.Context) (ProbeResult, error) {
+	return ProbeResult{Sysctl: -1}, ErrPlatformUnsupported
+}
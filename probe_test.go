@@ -0,0 +1,47 @@
+package tfo
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	before, beforeDial := ReadStats(), DefaultSupportCache.loadDial()
+	r, err := Probe(t.Context())
+	after, afterDial := ReadStats(), DefaultSupportCache.loadDial()
+	if runtime.GOOS != "linux" {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("Probe() error = %v, want %v", err, errors.ErrUnsupported)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Probe() = %+v", r)
+
+	if r.RoundTripErr != nil {
+		t.Error("RoundTripErr:", r.RoundTripErr)
+	}
+	if before.Dials != after.Dials || before.SYNBytes != after.SYNBytes {
+		t.Errorf("Probe() changed the package-wide counters: before %+v, after %+v", before, after)
+	}
+	if beforeDial != afterDial {
+		t.Errorf("Probe() changed DefaultSupportCache from %v to %v", beforeDial, afterDial)
+	}
+
+	if r.Sysctl < 0 {
+		return
+	}
+	enabled := r.ClientEnabled && r.ServerEnabled
+	if r.ConnectOptionRoundTrip && !enabled || r.SendtoRoundTrip && !enabled {
+		t.Error("round trip sent data in SYN with TFO disabled")
+	}
+	if enabled && !r.SendtoRoundTrip {
+		t.Error("SendtoRoundTrip = false with TFO enabled")
+	}
+	if enabled && r.ConnectOption && !r.ConnectOptionRoundTrip {
+		t.Error("ConnectOptionRoundTrip = false with TFO enabled")
+	}
+}
@@ -0,0 +1,101 @@
+package tfo
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+)
+
+// defaultSYNReadSize is the number of bytes read ahead for data in SYN when the MSS is unknown.
+// It fits in a single segment on typical paths with a 1500-byte MTU.
+const defaultSYNReadSize = 1400
+
+// DialReader is like [Dialer.DialContext] but takes the initial payload from r.
+//
+// It reads at most one MSS worth of data from r for data in SYN, with a single read,
+// so that it does not wait for more data than r has readily available. The MSS is
+// [SocketOptions.MaxSegment] if set, or the MSS cached by the kernel from a previous TFO
+// handshake with the destination if address is an IP address (Linux). Otherwise, 1400 bytes
+// are read. If synCap is positive, it further limits the number of bytes read.
+// The kernel may send part of the data after the handshake, if it does not fit in the SYN.
+//
+// Once the connection is established, the rest of r is copied to it until EOF.
+// ctx only applies to the dial, and not to the copy, which is not bounded by any deadline.
+//
+// It returns the number of bytes consumed from r, all of which were written to the connection.
+// On error, any established connection is closed, and the returned count reports how much of r
+// was consumed before the error occurred.
+func (d *Dialer) DialReader(ctx context.Context, network, address string, r io.Reader, synCap int) (net.Conn, int64, error) {
+	b := make([]byte, d.synReadSize(address, synCap))
+	n, err := readSome(r, b)
+	switch err {
+	case nil:
+	case io.EOF:
+		r = nil
+	default:
+		return nil, int64(n), err
+	}
+
+	c, err := d.DialContext(ctx, network, address, b[:n])
+	if err != nil {
+		return nil, int64(n), err
+	}
+	if r == nil {
+		return c, int64(n), nil
+	}
+
+	rest, err := io.Copy(c, r)
+	if err != nil {
+		c.Close()
+		return nil, int64(n) + rest, err
+	}
+	return c, int64(n) + rest, nil
+}
+
+// synReadSize returns the number of bytes to read ahead for data in SYN to address.
+func (d *Dialer) synReadSize(address string, synCap int) int {
+	size := defaultSYNReadSize
+	switch {
+	case d.SocketOptions.MaxSegment > 0:
+		size = d.SocketOptions.MaxSegment
+	case d.NetNS == nil:
+		// The kernel's cache is per network namespace.
+		if addrPort, err := netip.ParseAddrPort(address); err == nil {
+			if mss := cachedMSS(addrPort.Addr()); mss > 0 {
+				size = mss
+			}
+		}
+	}
+	if synCap > 0 {
+		size = min(size, synCap)
+	}
+	return size
+}
+
+// cachedMSS returns the MSS the kernel cached for addr from a previous TFO handshake, or 0 if there is none.
+func cachedMSS(addr netip.Addr) int {
+	entries, err := tcpMetrics(addr) // tcpmetrics_linux.go, tcpmetrics_stub.go
+	if err != nil || len(entries) == 0 {
+		return 0
+	}
+	return int(entries[0].FastOpenMSS)
+}
+
+// readSome reads into b with a single successful read, retrying reads that return no data and no error.
+func readSome(r io.Reader, b []byte) (int, error) {
+	for range 100 {
+		n, err := r.Read(b)
+		if n > 0 {
+			if err == io.EOF {
+				// Defer EOF to the next read, which the caller may skip.
+				err = nil
+			}
+			return n, err
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, io.ErrNoProgress
+}
@@ -0,0 +1,181 @@
+package tfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+)
+
+func testDialReader(t *testing.T, lc ListenConfig, d Dialer) {
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	payload := make([]byte, 64*1024)
+	rand.Read(payload)
+
+	for _, c := range []struct {
+		name    string
+		payload []byte
+		synCap  int
+		r       func(b []byte) io.Reader
+	}{
+		{"Empty", nil, 0, func(b []byte) io.Reader { return bytes.NewReader(b) }},
+		{"Short", hello, 0, func(b []byte) io.Reader { return bytes.NewReader(b) }},
+		{"OneByteReader", helloworld, 0, func(b []byte) io.Reader { return iotest.OneByteReader(bytes.NewReader(b)) }},
+		{"DataErrReader", helloworld, 0, func(b []byte) io.Reader { return iotest.DataErrReader(bytes.NewReader(b)) }},
+		{"SmallCap", helloworld, 4, func(b []byte) io.Reader { return bytes.NewReader(b) }},
+		{"Large", payload, 0, func(b []byte) io.Reader { return bytes.NewReader(b) }},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				conn, err := ln.Accept()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer conn.Close()
+				readUntilEOF(conn, c.payload, t)
+			}()
+
+			r := c.r(c.payload)
+			conn, n, err := d.DialReader(t.Context(), "tcp", ln.Addr().String(), r, c.synCap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if n != int64(len(c.payload)) {
+				t.Errorf("n = %d, want %d", n, len(c.payload))
+			}
+			conn.(*net.TCPConn).CloseWrite()
+			<-done
+		})
+	}
+}
+
+func TestDialReader(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testDialReader)
+	}
+}
+
+func TestDialReaderError(t *testing.T) {
+	errRead := errors.New("read error")
+	var d Dialer
+	c, n, err := d.DialReader(t.Context(), "tcp", "[::1]:1", iotest.ErrReader(errRead), 0)
+	if c != nil {
+		c.Close()
+	}
+	if err != errRead || n != 0 {
+		t.Errorf("DialReader() = %d, %v, want 0, %v", n, err, errRead)
+	}
+}
+
+// cancelReader returns hello, then cancels the context and returns world.
+type cancelReader struct {
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (r *cancelReader) Read(b []byte) (int, error) {
+	r.reads++
+	switch r.reads {
+	case 1:
+		return copy(b, hello), nil
+	case 2:
+		r.cancel()
+		return copy(b, world), nil
+	default:
+		return 0, io.EOF
+	}
+}
+
+// TestDialReaderStream ensures that the rest of the reader is copied after the dial,
+// and is not affected by the dial context.
+func TestDialReaderStream(t *testing.T) {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		readUntilEOF(conn, helloworld, t)
+	}()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var d Dialer
+	c, n, err := d.DialReader(ctx, "tcp", ln.Addr().String(), &cancelReader{cancel: cancel}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if n != int64(len(helloworld)) {
+		t.Errorf("n = %d, want %d", n, len(helloworld))
+	}
+	c.(*net.TCPConn).CloseWrite()
+	<-done
+}
+
+func TestDialReaderSYNReadSize(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		mss     int
+		address string
+		synCap  int
+		want    int
+	}{
+		{"Default", 0, "example.com:443", 0, defaultSYNReadSize},
+		{"Cap", 0, "example.com:443", 100, 100},
+		{"MaxSegment", 536, "example.com:443", 0, 536},
+		{"MaxSegmentCap", 536, "example.com:443", 100, 100},
+		{"LargeCap", 536, "example.com:443", 4096, 536},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			d := Dialer{SocketOptions: SocketOptions{MaxSegment: c.mss}}
+			if got := d.synReadSize(c.address, c.synCap); got != c.want {
+				t.Errorf("synReadSize(%q, %d) = %d, want %d", c.address, c.synCap, got, c.want)
+			}
+		})
+	}
+}
+
+func TestDialReaderCopyError(t *testing.T) {
+	s, err := newDiscardTCPServer(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	errRead := errors.New("read error")
+	r := io.MultiReader(bytes.NewReader(hello), bytes.NewReader(world), iotest.ErrReader(errRead))
+
+	var d Dialer
+	c, n, err := d.DialReader(t.Context(), "tcp", s.AddrPort().String(), r, 0)
+	if c != nil {
+		c.Close()
+		t.Error("DialReader returned a connection on error")
+	}
+	if !errors.Is(err, errRead) || n != int64(len(helloworld)) {
+		t.Errorf("DialReader() = %d, %v, want %d, %v", n, err, len(helloworld), errRead)
+	}
+}
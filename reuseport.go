@@ -0,0 +1,145 @@
+package tfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+// ListenerGroupOptions controls how [ListenConfig.ListenGroup] creates a listener group.
+type ListenerGroupOptions struct {
+	// Size is the number of listeners in the group.
+	// If zero or negative, [runtime.GOMAXPROCS] is used.
+	Size int
+
+	// IncomingCPU controls whether to steer connections to listeners by CPU with SO_INCOMING_CPU.
+	// When set, the i-th listener only accepts connections processed on CPU i modulo [runtime.NumCPU].
+	// For the best results, Size should be the number of CPUs, and the NIC queues should be mapped to CPUs.
+	//
+	// This is only supported on Linux. Steering within a SO_REUSEPORT group requires Linux 6.2 or later.
+	// Older kernels accept the option, but ignore it, and distribute connections by hash.
+	IncomingCPU bool
+}
+
+// ListenerGroup is a group of TFO-enabled listeners on the same address,
+// among which the kernel distributes incoming connections.
+//
+// Run one accept loop per listener, and call [ListenerGroup.Close] to close them all.
+type ListenerGroup struct {
+	// Listeners are the listeners in the group.
+	Listeners []*TCPListener
+}
+
+// Addr returns the address shared by the listeners.
+func (g *ListenerGroup) Addr() net.Addr {
+	return g.Listeners[0].Addr()
+}
+
+// Close closes all listeners in the group.
+func (g *ListenerGroup) Close() error {
+	errs := make([]error, 0, len(g.Listeners))
+	for _, ln := range g.Listeners {
+		errs = append(errs, ln.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns the sum of the counters of all listeners in the group.
+func (g *ListenerGroup) Stats() (stats ListenerStats) {
+	for _, ln := range g.Listeners {
+		s := ln.Stats()
+		stats.TFOAccepts += s.TFOAccepts
+		stats.PlainAccepts += s.PlainAccepts
+	}
+	return stats
+}
+
+// ListenGroup creates a group of listeners on the same address, with SO_REUSEPORT set,
+// so that the kernel distributes incoming connections among them.
+//
+// Every listener is created by [ListenConfig.ListenTCP] with the same configuration,
+// so they have consistent TFO backlog and key settings. If the port in address is 0,
+// the port of the first listener is used for the rest of the group.
+//
+// This is only supported on Linux, which uses SO_REUSEPORT, and FreeBSD, which uses SO_REUSEPORT_LB.
+func (lc *ListenConfig) ListenGroup(ctx context.Context, network, address string, opts ListenerGroupOptions) (*ListenerGroup, error) {
+	if !networkIsTCP(network) {
+		return nil, &net.OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: net.UnknownNetworkError(network)}
+	}
+
+	size := opts.Size
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	numCPU := runtime.NumCPU()
+
+	g := &ListenerGroup{
+		Listeners: make([]*TCPListener, 0, size),
+	}
+
+	for i := range size {
+		cpu := -1
+		if opts.IncomingCPU {
+			cpu = i % numCPU
+		}
+
+		ln, err := lc.withReusePort(cpu).ListenTCP(ctx, network, address)
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		g.Listeners = append(g.Listeners, ln)
+
+		if i == 0 {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				g.Close()
+				return nil, err
+			}
+			address = net.JoinHostPort(host, strconv.Itoa(ln.Addr().(*net.TCPAddr).Port))
+		}
+	}
+
+	return g, nil
+}
+
+// withReusePort returns a copy of lc, whose Control function also sets SO_REUSEPORT,
+// and SO_INCOMING_CPU to cpu if cpu is not negative.
+func (lc *ListenConfig) withReusePort(cpu int) *ListenConfig {
+	// Copy these values to avoid referencing lc in llc.Control.
+	ctrlFn := lc.Control
+	llc := *lc
+	llc.Control = func(network, address string, c syscall.RawConn) (err error) {
+		if ctrlFn != nil {
+			if err = ctrlFn(network, address, c); err != nil {
+				return err
+			}
+		}
+
+		if cerr := c.Control(func(fd uintptr) {
+			err = setReusePort(fd)
+		}); cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			return os.NewSyscallError("setsockopt("+reusePortSockoptName+")", err)
+		}
+
+		if cpu >= 0 {
+			if cerr := c.Control(func(fd uintptr) {
+				err = setIncomingCPU(fd, cpu)
+			}); cerr != nil {
+				return cerr
+			}
+			if err != nil {
+				return os.NewSyscallError("setsockopt(SO_INCOMING_CPU)", err)
+			}
+		}
+		return nil
+	}
+	return &llc
+}
@@ -0,0 +1,13 @@
+package tfo
+
+import "golang.org/x/sys/unix"
+
+const reusePortSockoptName = "SO_REUSEPORT_LB"
+
+func setReusePort(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT_LB, 1)
+}
+
+func setIncomingCPU(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
@@ -0,0 +1,13 @@
+package tfo
+
+import "golang.org/x/sys/unix"
+
+const reusePortSockoptName = "SO_REUSEPORT"
+
+func setReusePort(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+}
+
+func setIncomingCPU(fd uintptr, cpu int) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_INCOMING_CPU, cpu)
+}
@@ -0,0 +1,40 @@
+package tfo
+
+import (
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestListenGroupIncomingCPU(t *testing.T) {
+	var lc ListenConfig
+	g, err := lc.ListenGroup(t.Context(), "tcp", "[::1]:", ListenerGroupOptions{IncomingCPU: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	if len(g.Listeners) != runtime.GOMAXPROCS(0) {
+		t.Errorf("len(g.Listeners) = %d, want %d", len(g.Listeners), runtime.GOMAXPROCS(0))
+	}
+
+	for i, ln := range g.Listeners {
+		rawConn, err := ln.SyscallConn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cpu int
+		if cerr := rawConn.Control(func(fd uintptr) {
+			cpu, err = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_INCOMING_CPU)
+		}); cerr != nil {
+			t.Fatal(cerr)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i % runtime.NumCPU(); cpu != want {
+			t.Errorf("listener %d: SO_INCOMING_CPU = %d, want %d", i, cpu, want)
+		}
+	}
+}
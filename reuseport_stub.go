@@ -0,0 +1,13 @@
+//go:build !freebsd && !linux
+
+package tfo
+
+const reusePortSockoptName = "SO_REUSEPORT"
+
+func setReusePort(_ uintptr) error {
+	return ErrPlatformUnsupported
+}
+
+func setIncomingCPU(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
@@ -0,0 +1,75 @@
+package tfo
+
+import (
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func testListenGroup(t *testing.T, lc ListenConfig, d Dialer) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		_, err := lc.ListenGroup(t.Context(), "tcp", "[::1]:", ListenerGroupOptions{Size: 2})
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("ListenGroup error = %v, want %v", err, errors.ErrUnsupported)
+		}
+		return
+	}
+
+	const size = 4
+	g, err := lc.ListenGroup(t.Context(), "tcp", "[::1]:", ListenerGroupOptions{Size: size})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.Listeners) != size {
+		t.Fatalf("len(g.Listeners) = %d, want %d", len(g.Listeners), size)
+	}
+	for _, ln := range g.Listeners {
+		if ln.Addr().String() != g.Addr().String() {
+			t.Errorf("ln.Addr() = %v, want %v", ln.Addr(), g.Addr())
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, ln := range g.Listeners {
+		wg.Go(func() {
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				readUntilEOF(c, hello, t)
+				c.Close()
+			}
+		})
+	}
+
+	const conns = 16
+	for range conns {
+		c, err := d.DialContext(t.Context(), "tcp", g.Addr().String(), hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.(*net.TCPConn).CloseWrite()
+		readUntilEOF(c, nil, t)
+		c.Close()
+	}
+
+	if err = g.Close(); err != nil {
+		t.Error(err)
+	}
+	wg.Wait()
+
+	if stats := g.Stats(); stats.TFOAccepts+stats.PlainAccepts != conns {
+		t.Errorf("Stats() = %+v, want %d accepts", stats, conns)
+	}
+}
+
+// TestListenGroup ensures that all listeners in a group share the address, and close together.
+func TestListenGroup(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testListenGroup)
+	}
+}
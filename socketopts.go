@@ -0,0 +1,239 @@
+package tfo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// SocketOptions are socket options that [Dialer] and [ListenConfig] set on their sockets.
+// The options are set before the SYN is sent, after any Control or ControlContext function,
+// on every dial and listen path, including the sendmsg(2) and ConnectEx paths.
+//
+// The zero value of each field leaves the corresponding option at its default.
+// If setting an option fails, the dial or listen call fails with an error naming the option,
+// unless the option is not supported on the platform, and Fallback is set,
+// in which case the option is ignored.
+type SocketOptions struct {
+	// DisableNoDelay clears TCP_NODELAY on dialed connections. Like Go std, TCP_NODELAY is set by default.
+	// Accepted connections always have TCP_NODELAY set by Go std, and are not affected by this option.
+	DisableNoDelay bool
+
+	// Mark sets SO_MARK (Linux).
+	Mark uint32
+
+	// BindToDevice binds the socket to the named network interface with SO_BINDTODEVICE (Linux).
+	BindToDevice string
+
+	// TrafficClass sets IP_TOS on IPv4 sockets, and IPV6_TCLASS on IPv6 sockets.
+	// On dual-stack sockets, IP_TOS is also set on a best-effort basis.
+	// This is not supported on Windows.
+	TrafficClass int
+
+	// Congestion sets the congestion control algorithm with TCP_CONGESTION (Linux, FreeBSD).
+	Congestion string
+
+	// UserTimeout sets TCP_USER_TIMEOUT (Linux), rounded up to whole milliseconds.
+	UserTimeout time.Duration
+
+	// NotSentLowat sets TCP_NOTSENT_LOWAT (Linux, macOS).
+	NotSentLowat int
+
+	// MaxSegment sets TCP_MAXSEG. This is not supported on Windows.
+	MaxSegment int
+
+	// SendBuffer sets SO_SNDBUF.
+	SendBuffer int
+
+	// ReceiveBuffer sets SO_RCVBUF.
+	ReceiveBuffer int
+
+	// BindAddressNoPort sets IP_BIND_ADDRESS_NO_PORT (Linux) on dialer sockets, so that
+	// the source port is chosen at connect time, and can be shared by connections to
+	// different destinations. ListenConfig ignores this option.
+	BindAddressNoPort bool
+
+	// Transparent sets IP_TRANSPARENT on IPv4 sockets, and IPV6_TRANSPARENT on IPv6 sockets (Linux),
+	// or IP_BINDANY and IPV6_BINDANY (FreeBSD). This requires CAP_NET_ADMIN on Linux.
+	//
+	// On a listener, it allows accepting connections redirected by TPROXY, whose destination
+	// addresses are not local. Use [OriginalDestination] to get the destination of such connections.
+	// On a dialer, it allows binding [net.Dialer.LocalAddr] to a non-local address.
+	Transparent bool
+}
+
+// isZero returns whether o leaves all options at their defaults.
+func (o *SocketOptions) isZero() bool {
+	return *o == SocketOptions{}
+}
+
+// apply sets the options in o on fd, whose network is "tcp4" or "tcp6".
+// dialer controls whether dialer-only options are set.
+// If fallback is true, options not supported on the platform are ignored.
+// Failures are reported to trace, which may be nil.
+func (o *SocketOptions) apply(fd uintptr, network string, dialer, fallback bool, trace *DialTrace) error {
+	set := func(name string, err error) error {
+		if err == nil {
+			return nil
+		}
+		trace.sockoptError(name, err)
+		if fallback && errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		return os.NewSyscallError("setsockopt("+name+")", err)
+	}
+
+	if o.Mark != 0 {
+		if err := set("SO_MARK", setMark(fd, o.Mark)); err != nil {
+			return err
+		}
+	}
+	if o.BindToDevice != "" {
+		if err := set("SO_BINDTODEVICE", setBindToDevice(fd, o.BindToDevice)); err != nil {
+			return err
+		}
+	}
+	if o.TrafficClass != 0 {
+		name := "IP_TOS"
+		if network == "tcp6" {
+			name = "IPV6_TCLASS"
+		}
+		if err := set(name, setTrafficClass(fd, network, o.TrafficClass)); err != nil {
+			return err
+		}
+	}
+	if o.Congestion != "" {
+		if err := set("TCP_CONGESTION", setCongestion(fd, o.Congestion)); err != nil {
+			return err
+		}
+	}
+	if o.UserTimeout > 0 {
+		ms := int(min((o.UserTimeout+time.Millisecond-1)/time.Millisecond, math.MaxInt32))
+		if err := set("TCP_USER_TIMEOUT", setUserTimeout(fd, ms)); err != nil {
+			return err
+		}
+	}
+	if o.NotSentLowat != 0 {
+		if err := set("TCP_NOTSENT_LOWAT", setNotSentLowat(fd, o.NotSentLowat)); err != nil {
+			return err
+		}
+	}
+	if o.MaxSegment != 0 {
+		if err := set("TCP_MAXSEG", setMaxSegment(fd, o.MaxSegment)); err != nil {
+			return err
+		}
+	}
+	if o.SendBuffer != 0 {
+		if err := set("SO_SNDBUF", setSendBuffer(fd, o.SendBuffer)); err != nil {
+			return err
+		}
+	}
+	if o.ReceiveBuffer != 0 {
+		if err := set("SO_RCVBUF", setReceiveBuffer(fd, o.ReceiveBuffer)); err != nil {
+			return err
+		}
+	}
+	if dialer && o.BindAddressNoPort {
+		if err := set("IP_BIND_ADDRESS_NO_PORT", setBindAddressNoPort(fd)); err != nil {
+			return err
+		}
+	}
+	if o.Transparent {
+		if err := set(transparentSockoptName(network), setTransparent(fd, network)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transparentSockoptName returns the name of the option set by setTransparent on sockets of network.
+func transparentSockoptName(network string) string {
+	if runtime.GOOS == "freebsd" {
+		if network == "tcp6" {
+			return "IPV6_BINDANY"
+		}
+		return "IP_BINDANY"
+	}
+	if network == "tcp6" {
+		return "IPV6_TRANSPARENT"
+	}
+	return "IP_TRANSPARENT"
+}
+
+// controlContext returns d's ControlContext function, or its Control function adapted to one.
+func (d *Dialer) controlContext() func(context.Context, string, string, syscall.RawConn) error {
+	if d.ControlContext != nil {
+		return d.ControlContext
+	}
+	if ctrlFn := d.Control; ctrlFn != nil {
+		return func(_ context.Context, network, address string, c syscall.RawConn) error {
+			return ctrlFn(network, address, c)
+		}
+	}
+	return nil
+}
+
+// withSocketOptions returns a copy of d, whose ControlContext function also sets d.SocketOptions,
+// and whose SocketOptions is cleared.
+func (d *Dialer) withSocketOptions() *Dialer {
+	// Copy these values to avoid referencing d in nd.ControlContext.
+	ctrlCtxFn := d.controlContext()
+	opts := d.SocketOptions
+	fallback := d.Fallback
+	nd := *d
+	nd.SocketOptions = SocketOptions{}
+	nd.disableNoDelay = opts.DisableNoDelay
+	nd.ControlContext = func(ctx context.Context, network, address string, c syscall.RawConn) (err error) {
+		if ctrlCtxFn != nil {
+			if err = ctrlCtxFn(ctx, network, address, c); err != nil {
+				return err
+			}
+		}
+
+		if cerr := c.Control(func(fd uintptr) {
+			err = opts.apply(fd, network, true, fallback, ContextDialTrace(ctx))
+		}); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+	return &nd
+}
+
+// afterDial applies the options in o that Go std overrides when creating c.
+func (o *SocketOptions) afterDial(c net.Conn) {
+	if tc, ok := c.(*net.TCPConn); ok && o.DisableNoDelay {
+		_ = tc.SetNoDelay(false)
+	}
+}
+
+// withSocketOptions returns a copy of lc, whose Control function also sets lc.SocketOptions,
+// and whose SocketOptions is cleared.
+func (lc *ListenConfig) withSocketOptions() *ListenConfig {
+	// Copy these values to avoid referencing lc in llc.Control.
+	ctrlFn := lc.Control
+	opts := lc.SocketOptions
+	fallback := lc.Fallback
+	llc := *lc
+	llc.SocketOptions = SocketOptions{}
+	llc.Control = func(network, address string, c syscall.RawConn) (err error) {
+		if ctrlFn != nil {
+			if err = ctrlFn(network, address, c); err != nil {
+				return err
+			}
+		}
+
+		if cerr := c.Control(func(fd uintptr) {
+			err = opts.apply(fd, network, false, fallback, nil)
+		}); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+	return &llc
+}
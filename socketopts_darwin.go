@@ -0,0 +1,31 @@
+package tfo
+
+import "golang.org/x/sys/unix"
+
+func setMark(_ uintptr, _ uint32) error {
+	return ErrPlatformUnsupported
+}
+
+func setBindToDevice(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
+
+func setCongestion(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
+
+func setUserTimeout(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setNotSentLowat(fd uintptr, lowat int) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_NOTSENT_LOWAT, lowat)
+}
+
+func setBindAddressNoPort(_ uintptr) error {
+	return ErrPlatformUnsupported
+}
+
+func setTransparent(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
@@ -0,0 +1,34 @@
+package tfo
+
+import "golang.org/x/sys/unix"
+
+func setMark(_ uintptr, _ uint32) error {
+	return ErrPlatformUnsupported
+}
+
+func setBindToDevice(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
+
+func setCongestion(fd uintptr, name string) error {
+	return unix.SetsockoptString(int(fd), unix.IPPROTO_TCP, unix.TCP_CONGESTION, name)
+}
+
+func setUserTimeout(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setNotSentLowat(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setBindAddressNoPort(_ uintptr) error {
+	return ErrPlatformUnsupported
+}
+
+func setTransparent(fd uintptr, network string) error {
+	if network == "tcp6" {
+		return unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_BINDANY, 1)
+	}
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_BINDANY, 1)
+}
@@ -0,0 +1,36 @@
+package tfo
+
+import "golang.org/x/sys/unix"
+
+func setMark(fd uintptr, mark uint32) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_MARK, int(mark))
+}
+
+func setBindToDevice(fd uintptr, ifname string) error {
+	return unix.BindToDevice(int(fd), ifname)
+}
+
+func setCongestion(fd uintptr, name string) error {
+	return unix.SetsockoptString(int(fd), unix.IPPROTO_TCP, unix.TCP_CONGESTION, name)
+}
+
+func setUserTimeout(fd uintptr, ms int) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, ms)
+}
+
+func setNotSentLowat(fd uintptr, lowat int) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_NOTSENT_LOWAT, lowat)
+}
+
+func setBindAddressNoPort(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_BIND_ADDRESS_NO_PORT, 1)
+}
+
+// setTransparent sets IPV6_TRANSPARENT on IPv6 sockets, which also covers IPv4 traffic
+// on dual-stack sockets, or IP_TRANSPARENT on IPv4 sockets.
+func setTransparent(fd uintptr, network string) error {
+	if network == "tcp6" {
+		return unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TRANSPARENT, 1)
+	}
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TRANSPARENT, 1)
+}
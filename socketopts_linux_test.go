@@ -0,0 +1,199 @@
+package tfo
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func getsockoptInt(t *testing.T, sc syscall.Conn, level, opt int) int {
+	t.Helper()
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v int
+	if cerr := rawConn.Control(func(fd uintptr) {
+		v, err = unix.GetsockoptInt(int(fd), level, opt)
+	}); cerr != nil {
+		t.Fatal(cerr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func getsockoptString(t *testing.T, sc syscall.Conn, level, opt int) string {
+	t.Helper()
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v string
+	if cerr := rawConn.Control(func(fd uintptr) {
+		v, err = unix.GetsockoptString(int(fd), level, opt)
+	}); cerr != nil {
+		t.Fatal(cerr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// canSetMark returns whether the process is allowed to set SO_MARK.
+func canSetMark() bool {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return false
+	}
+	defer unix.Close(fd)
+	return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_MARK, 1) == nil
+}
+
+func testSocketOptions(t *testing.T, lc ListenConfig, d Dialer) {
+	lc.SocketOptions = SocketOptions{
+		Congestion:  "reno",
+		UserTimeout: 3 * time.Second,
+	}
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lntcp := ln.(*net.TCPListener)
+	defer lntcp.Close()
+
+	if got := getsockoptString(t, lntcp, unix.IPPROTO_TCP, unix.TCP_CONGESTION); got != "reno" {
+		t.Errorf("listener TCP_CONGESTION = %q, want %q", got, "reno")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := lntcp.AcceptTCP()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		readUntilEOF(conn, hello, t)
+		if got := getsockoptInt(t, conn, unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT); got != 3000 {
+			t.Errorf("accepted TCP_USER_TIMEOUT = %d, want 3000", got)
+		}
+	}()
+
+	var mark uint32
+	if canSetMark() {
+		mark = 42
+	}
+	d.SocketOptions = SocketOptions{
+		DisableNoDelay:    true,
+		Mark:              mark,
+		TrafficClass:      0x20,
+		Congestion:        "reno",
+		UserTimeout:       1500 * time.Microsecond,
+		NotSentLowat:      16384,
+		SendBuffer:        65536,
+		ReceiveBuffer:     65536,
+		BindAddressNoPort: true,
+	}
+	// Data written during the dial call must also be subject to Nagle's algorithm.
+	noDelayBeforeSYN := -1
+	d.Control = func(_, _ string, c syscall.RawConn) error {
+		return c.Control(func(fd uintptr) {
+			noDelayBeforeSYN, _ = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_NODELAY)
+		})
+	}
+
+	c, err := d.DialContext(t.Context(), "tcp", ln.Addr().String(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := c.(*net.TCPConn)
+	defer tc.Close()
+
+	for _, o := range []struct {
+		name       string
+		level, opt int
+		want       int
+	}{
+		{"TCP_NODELAY", unix.IPPROTO_TCP, unix.TCP_NODELAY, 0},
+		{"SO_MARK", unix.SOL_SOCKET, unix.SO_MARK, int(mark)},
+		{"IPV6_TCLASS", unix.IPPROTO_IPV6, unix.IPV6_TCLASS, 0x20},
+		{"TCP_USER_TIMEOUT", unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, 2},
+		{"TCP_NOTSENT_LOWAT", unix.IPPROTO_TCP, unix.TCP_NOTSENT_LOWAT, 16384},
+		{"IP_BIND_ADDRESS_NO_PORT", unix.IPPROTO_IP, unix.IP_BIND_ADDRESS_NO_PORT, 1},
+	} {
+		if got := getsockoptInt(t, tc, o.level, o.opt); got != o.want {
+			t.Errorf("%s = %d, want %d", o.name, got, o.want)
+		}
+	}
+	if noDelayBeforeSYN != 0 {
+		t.Errorf("TCP_NODELAY before SYN = %d, want 0", noDelayBeforeSYN)
+	}
+	// The kernel doubles the buffer sizes to allow space for bookkeeping overhead.
+	if got := getsockoptInt(t, tc, unix.SOL_SOCKET, unix.SO_SNDBUF); got != 2*65536 {
+		t.Errorf("SO_SNDBUF = %d, want %d", got, 2*65536)
+	}
+	if got := getsockoptInt(t, tc, unix.SOL_SOCKET, unix.SO_RCVBUF); got != 2*65536 {
+		t.Errorf("SO_RCVBUF = %d, want %d", got, 2*65536)
+	}
+	if got := getsockoptString(t, tc, unix.IPPROTO_TCP, unix.TCP_CONGESTION); got != "reno" {
+		t.Errorf("TCP_CONGESTION = %q, want %q", got, "reno")
+	}
+
+	tc.CloseWrite()
+	<-done
+}
+
+// TestSocketOptions ensures that [SocketOptions] are set on every dial and listen path.
+func TestSocketOptions(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testSocketOptions)
+	}
+}
+
+func TestSocketOptionsError(t *testing.T) {
+	lc := ListenConfig{
+		SocketOptions: SocketOptions{Congestion: "nonexistent"},
+	}
+	_, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if !errors.Is(err, unix.ENOENT) {
+		t.Fatalf("err = %v, want %v", err, unix.ENOENT)
+	}
+	var serr *os.SyscallError
+	if !errors.As(err, &serr) || serr.Syscall != "setsockopt(TCP_CONGESTION)" {
+		t.Errorf("err = %v, want setsockopt(TCP_CONGESTION) error", err)
+	}
+
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	raddr := ln.Addr().(*net.TCPAddr).AddrPort()
+
+	// DialTCP takes the sendto(MSG_FASTOPEN) path in the LinuxSendto state.
+	for _, setRuntimeFallback := range []runtimeFallbackHelperFunc{runtimeFallbackAsIs, runtimeFallbackSetDialLinuxSendto} {
+		d := Dialer{
+			Fallback:      true,
+			SocketOptions: SocketOptions{Congestion: "nonexistent"},
+			SupportCache:  new(SupportCache),
+		}
+		setRuntimeFallback(d.SupportCache)
+		c, err := d.DialTCP(t.Context(), "tcp", netip.AddrPort{}, raddr, hello)
+		if err == nil {
+			c.Close()
+		}
+		if !errors.As(err, &serr) || serr.Syscall != "setsockopt(TCP_CONGESTION)" {
+			t.Errorf("err = %v, want setsockopt(TCP_CONGESTION) error", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !darwin && !freebsd && !linux && !windows
+
+package tfo
+
+func setMark(_ uintptr, _ uint32) error {
+	return ErrPlatformUnsupported
+}
+
+func setBindToDevice(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
+
+func setTrafficClass(_ uintptr, _ string, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setCongestion(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
+
+func setUserTimeout(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setNotSentLowat(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setMaxSegment(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setSendBuffer(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setReceiveBuffer(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setBindAddressNoPort(_ uintptr) error {
+	return ErrPlatformUnsupported
+}
+
+func setTransparent(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
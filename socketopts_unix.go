@@ -0,0 +1,31 @@
+//go:build darwin || freebsd || linux
+
+package tfo
+
+import "golang.org/x/sys/unix"
+
+func setTrafficClass(fd uintptr, network string, tclass int) error {
+	if network == "tcp6" {
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, tclass); err != nil {
+			return err
+		}
+		// Also cover IPv4 traffic on dual-stack sockets. The error is ignored, because IPV6_TCLASS,
+		// the option that applies to the socket's own family, has been set. Linux accepts IP_TOS
+		// on any IPv6 socket, but macOS and FreeBSD reject it, leaving no way to cover IPv4 traffic.
+		_ = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tclass)
+		return nil
+	}
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tclass)
+}
+
+func setMaxSegment(fd uintptr, mss int) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_MAXSEG, mss)
+}
+
+func setSendBuffer(fd uintptr, size int) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF, size)
+}
+
+func setReceiveBuffer(fd uintptr, size int) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF, size)
+}
@@ -0,0 +1,47 @@
+package tfo
+
+import "golang.org/x/sys/windows"
+
+func setMark(_ uintptr, _ uint32) error {
+	return ErrPlatformUnsupported
+}
+
+func setBindToDevice(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
+
+func setTrafficClass(_ uintptr, _ string, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setCongestion(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
+
+func setUserTimeout(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setNotSentLowat(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setMaxSegment(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
+
+func setSendBuffer(fd uintptr, size int) error {
+	return windows.SetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_SNDBUF, size)
+}
+
+func setReceiveBuffer(fd uintptr, size int) error {
+	return windows.SetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_RCVBUF, size)
+}
+
+func setBindAddressNoPort(_ uintptr) error {
+	return ErrPlatformUnsupported
+}
+
+func setTransparent(_ uintptr, _ string) error {
+	return ErrPlatformUnsupported
+}
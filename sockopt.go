@@ -1,5 +1,12 @@
 package tfo
 
+import (
+	"errors"
+	"math"
+	"os"
+	"time"
+)
+
 // SetTFOListener enables TCP Fast Open on the listener.
 // On platforms where a backlog argument is required, Go std's listen(2) backlog is used.
 // To specify a custom backlog, use [SetTFOListenerWithBacklog].
@@ -18,3 +25,47 @@ func SetTFOListenerWithBacklog(fd uintptr, backlog int) error {
 func SetTFODialer(fd uintptr) error {
 	return setTFODialer(fd) // sockopt_darwin.go, sockopt_linux.go, sockopt_connect_generic.go, sockopt_stub.go
 }
+
+// SetTFONoCookie enables cookieless TCP Fast Open on the socket with TCP_FASTOPEN_NO_COOKIE.
+// On a dialer socket, data is sent in SYN without a cookie. On a listener socket,
+// data in SYN is accepted without a cookie.
+//
+// This is only supported on Linux 4.15 and later.
+// If the kernel does not recognize the option, the returned error matches [errors.ErrUnsupported].
+func SetTFONoCookie(fd uintptr) error {
+	return setTFONoCookie(fd) // sockopt_linux.go, sockopt_nocookie_stub.go
+}
+
+// SetDeferAccept sets TCP_DEFER_ACCEPT on the listener, so that accept(2) only returns
+// a connection once data has arrived on it, or timeout has elapsed.
+// The timeout is rounded up to whole seconds. A non-positive timeout disables the option.
+//
+// This is only supported on Linux.
+func SetDeferAccept(fd uintptr, timeout time.Duration) error {
+	return setDeferAccept(fd, deferAcceptSeconds(timeout)) // sockopt_linux.go, sockopt_deferaccept_stub.go
+}
+
+// deferAcceptSeconds converts timeout to the seconds value of TCP_DEFER_ACCEPT.
+func deferAcceptSeconds(timeout time.Duration) int {
+	if timeout <= 0 {
+		return 0
+	}
+	return int(min((timeout+time.Second-1)/time.Second, math.MaxInt32))
+}
+
+// setTFONoCookieIfEnabled sets TCP_FASTOPEN_NO_COOKIE on fd if noCookie is true.
+// If fallback is true, lack of support is ignored, and TFO proceeds with cookies.
+// Failures are reported to trace, which may be nil.
+func setTFONoCookieIfEnabled(fd uintptr, noCookie, fallback bool, trace *DialTrace) error {
+	if !noCookie {
+		return nil
+	}
+	if err := setTFONoCookie(fd); err != nil {
+		trace.sockoptError("TCP_FASTOPEN_NO_COOKIE", err)
+		if fallback && errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		return os.NewSyscallError("setsockopt(TCP_FASTOPEN_NO_COOKIE)", err)
+	}
+	return nil
+}
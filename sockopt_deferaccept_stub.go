@@ -0,0 +1,7 @@
+//go:build !linux
+
+package tfo
+
+func setDeferAccept(_ uintptr, _ int) error {
+	return ErrPlatformUnsupported
+}
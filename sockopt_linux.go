@@ -1,9 +1,12 @@
 package tfo
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"sync"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
@@ -45,3 +48,33 @@ var maxListenerBacklog = sync.OnceValue(func() int {
 func setTFODialer(fd uintptr) error {
 	return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1)
 }
+
+func setTFONoCookie(fd uintptr) error {
+	err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_NO_COOKIE, 1)
+	if err == unix.ENOPROTOOPT {
+		// Kernels before 4.15 do not recognize the option.
+		return fmt.Errorf("%w (%w)", errors.ErrUnsupported, err)
+	}
+	return err
+}
+
+func setDeferAccept(fd uintptr, secs int) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_DEFER_ACCEPT, secs)
+}
+
+func setTFOKeys(fd uintptr, keys []TFOKey) error {
+	b := make([]byte, 0, len(keys)*len(TFOKey{}))
+	for _, key := range keys {
+		b = append(b, key[:]...)
+	}
+	return unix.SetsockoptString(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_KEY, string(b))
+}
+
+func getTFOKeys(fd uintptr) ([]TFOKey, error) {
+	var buf [maxTFOKeys]TFOKey
+	vallen := uint32(len(buf) * len(TFOKey{}))
+	if _, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, fd, unix.IPPROTO_TCP, unix.TCP_FASTOPEN_KEY, uintptr(unsafe.Pointer(&buf)), uintptr(unsafe.Pointer(&vallen)), 0); errno != 0 {
+		return nil, errno
+	}
+	return buf[:int(vallen)/len(TFOKey{})], nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package tfo
+
+func setTFONoCookie(_ uintptr) error {
+	return ErrPlatformUnsupported
+}
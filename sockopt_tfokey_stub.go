@@ -0,0 +1,11 @@
+//go:build !linux
+
+package tfo
+
+func setTFOKeys(_ uintptr, _ []TFOKey) error {
+	return ErrPlatformUnsupported
+}
+
+func getTFOKeys(_ uintptr) ([]TFOKey, error) {
+	return nil, ErrPlatformUnsupported
+}
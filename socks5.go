@@ -0,0 +1,306 @@
+package tfo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+)
+
+// SOCKS5 protocol constants from RFC 1928 and RFC 1929.
+const (
+	socks5Version = 5
+
+	socks5MethodNoAuth       = 0
+	socks5MethodUsernamePass = 2
+	socks5MethodNoAcceptable = 0xff
+
+	socks5UsernamePassVersion = 1
+
+	socks5CmdConnect = 1
+
+	socks5AtypIPv4       = 1
+	socks5AtypDomainName = 3
+	socks5AtypIPv6       = 4
+)
+
+var (
+	errSOCKS5NoAcceptableMethods = errors.New("socks5: no acceptable authentication methods")
+	errSOCKS5UnexpectedMethod    = errors.New("socks5: proxy selected an unexpected authentication method")
+	errSOCKS5AuthFailed          = errors.New("socks5: username/password authentication failed")
+	errSOCKS5BadVersion          = errors.New("socks5: unexpected protocol version")
+	errSOCKS5BadAddressType      = errors.New("socks5: unexpected address type")
+	errSOCKS5CredentialsTooLong  = errors.New("socks5: username or password too long")
+	errSOCKS5DomainNameTooLong   = errors.New("socks5: domain name too long")
+)
+
+// SOCKS5ReplyError is a non-success reply code from a SOCKS5 proxy.
+type SOCKS5ReplyError uint8
+
+// Error implements [error].
+func (e SOCKS5ReplyError) Error() string {
+	switch e {
+	case 1:
+		return "socks5: general SOCKS server failure"
+	case 2:
+		return "socks5: connection not allowed by ruleset"
+	case 3:
+		return "socks5: network unreachable"
+	case 4:
+		return "socks5: host unreachable"
+	case 5:
+		return "socks5: connection refused"
+	case 6:
+		return "socks5: TTL expired"
+	case 7:
+		return "socks5: command not supported"
+	case 8:
+		return "socks5: address type not supported"
+	default:
+		return "socks5: unknown reply code " + strconv.Itoa(int(e))
+	}
+}
+
+// SOCKS5Dialer connects to targets through a SOCKS5 proxy (RFC 1928),
+// sending the method selection greeting in the SYN whenever possible.
+//
+// By default, the dialer waits for the proxy's reply at each step of the handshake.
+// When [SOCKS5Dialer.Pipeline] is set, the greeting, the authentication request,
+// the CONNECT request, and the initial payload are all sent in the first flight,
+// saving the round trips of the handshake.
+type SOCKS5Dialer struct {
+	// NetDialer is the optional dialer to use for the connections to the proxy.
+	// A nil NetDialer is equivalent to the zero [Dialer].
+	NetDialer *Dialer
+
+	// ProxyNetwork is the network of the proxy. If empty, "tcp" is used.
+	ProxyNetwork string
+
+	// ProxyAddress is the address of the proxy.
+	ProxyAddress string
+
+	// Username and Password are the optional credentials for
+	// username/password authentication (RFC 1929).
+	// If Username is empty, no authentication is offered.
+	Username string
+	Password string
+
+	// Pipeline controls whether to send the whole handshake and the initial payload
+	// in the first flight, without waiting for the proxy's replies.
+	//
+	// The greeting then offers a single method: username/password authentication
+	// if Username is set, or no authentication otherwise. This only works if the proxy
+	// is known in advance to accept that method.
+	Pipeline bool
+}
+
+func (d *SOCKS5Dialer) netDialer() *Dialer {
+	if d.NetDialer != nil {
+		return d.NetDialer
+	}
+	return new(Dialer)
+}
+
+func (d *SOCKS5Dialer) proxyNetwork() string {
+	if d.ProxyNetwork != "" {
+		return d.ProxyNetwork
+	}
+	return "tcp"
+}
+
+// Dial is like [SOCKS5Dialer.DialContext] but uses [context.Background].
+func (d *SOCKS5Dialer) Dial(network, address string, b []byte) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address, b)
+}
+
+// DialContext connects to address on the named network through the proxy,
+// and returns a connection to the target. b is written to the target,
+// as part of the first flight when [SOCKS5Dialer.Pipeline] is set,
+// or after the proxy has connected to the target otherwise.
+//
+// The network must be a TCP network name. The address may be a domain name,
+// which is resolved by the proxy.
+func (d *SOCKS5Dialer) DialContext(ctx context.Context, network, address string, b []byte) (net.Conn, error) {
+	if !networkIsTCP(network) {
+		return nil, &net.OpError{Op: "socks connect", Net: network, Source: nil, Addr: nil, Err: net.UnknownNetworkError(network)}
+	}
+
+	if len(d.Username) > 255 || len(d.Password) > 255 {
+		return nil, &net.OpError{Op: "socks connect", Net: network, Source: nil, Addr: nil, Err: errSOCKS5CredentialsTooLong}
+	}
+
+	req, err := appendSOCKS5ConnectRequest(nil, address)
+	if err != nil {
+		return nil, &net.OpError{Op: "socks connect", Net: network, Source: nil, Addr: nil, Err: err}
+	}
+
+	netDialer := d.netDialer()
+	ctx, cancel := netDialer.dialCtx(ctx)
+	defer cancel()
+
+	var (
+		method byte = socks5MethodNoAuth
+		first  []byte
+	)
+	if d.Username != "" {
+		method = socks5MethodUsernamePass
+	}
+	if d.Pipeline {
+		first = append(first, socks5Version, 1, method)
+		if method == socks5MethodUsernamePass {
+			first = d.appendUsernamePassRequest(first)
+		}
+		first = append(first, req...)
+		first = append(first, b...)
+	} else if method == socks5MethodUsernamePass {
+		first = []byte{socks5Version, 2, socks5MethodNoAuth, socks5MethodUsernamePass}
+	} else {
+		first = []byte{socks5Version, 1, socks5MethodNoAuth}
+	}
+
+	c, err := netDialer.DialContext(ctx, d.proxyNetwork(), d.ProxyAddress, first)
+	if err != nil {
+		return nil, err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.SetDeadline(aLongTimeAgo)
+	})
+	err = d.handshake(c, req, b)
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		return nil, &net.OpError{Op: "socks connect", Net: network, Source: c.RemoteAddr(), Addr: nil, Err: err}
+	}
+	return c, nil
+}
+
+// handshake completes the handshake on c after the first flight.
+func (d *SOCKS5Dialer) handshake(c net.Conn, req, b []byte) error {
+	var buf [2]byte
+	if _, err := io.ReadFull(c, buf[:]); err != nil {
+		return err
+	}
+	if buf[0] != socks5Version {
+		return errSOCKS5BadVersion
+	}
+
+	switch buf[1] {
+	case socks5MethodNoAuth:
+		if d.Pipeline && d.Username != "" {
+			return errSOCKS5UnexpectedMethod
+		}
+	case socks5MethodUsernamePass:
+		if d.Username == "" {
+			return errSOCKS5UnexpectedMethod
+		}
+		if !d.Pipeline {
+			if _, err := c.Write(d.appendUsernamePassRequest(nil)); err != nil {
+				return err
+			}
+		}
+		if _, err := io.ReadFull(c, buf[:]); err != nil {
+			return err
+		}
+		if buf[0] != socks5UsernamePassVersion {
+			return errSOCKS5BadVersion
+		}
+		if buf[1] != 0 {
+			return errSOCKS5AuthFailed
+		}
+	case socks5MethodNoAcceptable:
+		return errSOCKS5NoAcceptableMethods
+	default:
+		return errSOCKS5UnexpectedMethod
+	}
+
+	if !d.Pipeline {
+		if _, err := c.Write(req); err != nil {
+			return err
+		}
+	}
+
+	if err := readSOCKS5Reply(c); err != nil {
+		return err
+	}
+
+	if !d.Pipeline && len(b) > 0 {
+		if _, err := c.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendUsernamePassRequest appends the username/password authentication request to b.
+func (d *SOCKS5Dialer) appendUsernamePassRequest(b []byte) []byte {
+	b = append(b, socks5UsernamePassVersion, byte(len(d.Username)))
+	b = append(b, d.Username...)
+	b = append(b, byte(len(d.Password)))
+	return append(b, d.Password...)
+}
+
+// appendSOCKS5ConnectRequest appends the CONNECT request for address to b.
+func appendSOCKS5ConnectRequest(b []byte, address string) ([]byte, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return nil, &net.AddrError{Err: "invalid port", Addr: address}
+	}
+
+	b = append(b, socks5Version, socks5CmdConnect, 0)
+	if ip, err := netip.ParseAddr(host); err == nil {
+		ip = ip.Unmap()
+		if ip.Is4() {
+			b = append(b, socks5AtypIPv4)
+		} else {
+			b = append(b, socks5AtypIPv6)
+		}
+		b = append(b, ip.AsSlice()...)
+	} else {
+		if len(host) > 255 {
+			return nil, errSOCKS5DomainNameTooLong
+		}
+		b = append(b, socks5AtypDomainName, byte(len(host)))
+		b = append(b, host...)
+	}
+	return append(b, byte(port>>8), byte(port)), nil
+}
+
+// readSOCKS5Reply reads a reply to a request, without reading past its end.
+func readSOCKS5Reply(r io.Reader) error {
+	var buf [4 + 1 + 255 + 2]byte
+	if _, err := io.ReadFull(r, buf[:4]); err != nil {
+		return err
+	}
+	if buf[0] != socks5Version {
+		return errSOCKS5BadVersion
+	}
+	if buf[1] != 0 {
+		return SOCKS5ReplyError(buf[1])
+	}
+
+	var addrLen int
+	switch buf[3] {
+	case socks5AtypIPv4:
+		addrLen = 4
+	case socks5AtypIPv6:
+		addrLen = 16
+	case socks5AtypDomainName:
+		if _, err := io.ReadFull(r, buf[4:5]); err != nil {
+			return err
+		}
+		addrLen = int(buf[4])
+	default:
+		return errSOCKS5BadAddressType
+	}
+	_, err := io.ReadFull(r, buf[:addrLen+2])
+	return err
+}
@@ -0,0 +1,288 @@
+package tfo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+)
+
+// socks5TestServer is an in-process SOCKS5 proxy stand-in that supports CONNECT.
+type socks5TestServer struct {
+	ln       net.Listener
+	username string
+	password string
+
+	// reply, if non-zero, is returned for every CONNECT request.
+	reply byte
+}
+
+func newSOCKS5TestServer(t *testing.T, lc ListenConfig, username, password string, reply byte) *socks5TestServer {
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5TestServer{ln: ln, username: username, password: password, reply: reply}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn.(*net.TCPConn))
+		}
+	}()
+	return s
+}
+
+func (s *socks5TestServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *socks5TestServer) Close() {
+	s.ln.Close()
+}
+
+func (s *socks5TestServer) handle(c *net.TCPConn) {
+	defer c.Close()
+
+	// Greeting.
+	buf := make([]byte, 512)
+	if _, err := io.ReadFull(c, buf[:2]); err != nil || buf[0] != socks5Version {
+		return
+	}
+	methods := buf[2 : 2+buf[1]]
+	if _, err := io.ReadFull(c, methods); err != nil {
+		return
+	}
+	method := byte(socks5MethodNoAuth)
+	if s.username != "" {
+		method = socks5MethodUsernamePass
+	}
+	if !bytes.Contains(methods, []byte{method}) {
+		c.Write([]byte{socks5Version, socks5MethodNoAcceptable})
+		return
+	}
+	if _, err := c.Write([]byte{socks5Version, method}); err != nil {
+		return
+	}
+
+	// Username/password authentication.
+	if method == socks5MethodUsernamePass {
+		if _, err := io.ReadFull(c, buf[:2]); err != nil {
+			return
+		}
+		username := make([]byte, buf[1])
+		if _, err := io.ReadFull(c, username); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(c, buf[:1]); err != nil {
+			return
+		}
+		password := make([]byte, buf[0])
+		if _, err := io.ReadFull(c, password); err != nil {
+			return
+		}
+		if string(username) != s.username || string(password) != s.password {
+			c.Write([]byte{socks5UsernamePassVersion, 1})
+			return
+		}
+		if _, err := c.Write([]byte{socks5UsernamePassVersion, 0}); err != nil {
+			return
+		}
+	}
+
+	// CONNECT request.
+	if _, err := io.ReadFull(c, buf[:4]); err != nil || buf[1] != socks5CmdConnect {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case socks5AtypIPv4, socks5AtypIPv6:
+		addr := make([]byte, 4)
+		if buf[3] == socks5AtypIPv6 {
+			addr = make([]byte, 16)
+		}
+		if _, err := io.ReadFull(c, addr); err != nil {
+			return
+		}
+		host = net.IP(addr).String()
+	case socks5AtypDomainName:
+		if _, err := io.ReadFull(c, buf[:1]); err != nil {
+			return
+		}
+		name := make([]byte, buf[0])
+		if _, err := io.ReadFull(c, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		return
+	}
+	if _, err := io.ReadFull(c, buf[:2]); err != nil {
+		return
+	}
+	port := int(buf[0])<<8 | int(buf[1])
+
+	if s.reply != 0 {
+		c.Write([]byte{socks5Version, s.reply, 0, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		c.Write([]byte{socks5Version, 5, 0, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+
+	// Reply with a domain name bound address, to exercise the variable-length parsing.
+	reply := []byte{socks5Version, 0, 0, socks5AtypDomainName, 9}
+	reply = append(reply, "localhost"...)
+	reply = append(reply, 0, 0)
+	if _, err = c.Write(reply); err != nil {
+		return
+	}
+
+	go func() {
+		io.Copy(target, c)
+		target.(*net.TCPConn).CloseWrite()
+	}()
+	io.Copy(c, target)
+}
+
+func testSOCKS5Dialer(t *testing.T, lc ListenConfig, d Dialer) {
+	target, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				readUntilEOF(conn, helloworld, t)
+				write(conn, worldhello, t)
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(target.Addr().String())
+
+	for _, c := range []struct {
+		name     string
+		username string
+		pipeline bool
+		address  string
+	}{
+		{"NoAuth", "", false, target.Addr().String()},
+		{"NoAuthPipeline", "", true, target.Addr().String()},
+		{"UsernamePass", "user", false, target.Addr().String()},
+		{"UsernamePassPipeline", "user", true, target.Addr().String()},
+		{"DomainName", "", true, net.JoinHostPort("localhost", port)},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := newSOCKS5TestServer(t, lc, c.username, "pass", 0)
+			defer s.Close()
+
+			sd := SOCKS5Dialer{
+				NetDialer:    &d,
+				ProxyAddress: s.Addr(),
+				Username:     c.username,
+				Password:     "pass",
+				Pipeline:     c.pipeline,
+			}
+			conn, err := sd.DialContext(t.Context(), "tcp", c.address, hello)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			write(conn, world, t)
+			conn.(*net.TCPConn).CloseWrite()
+			readUntilEOF(conn, worldhello, t)
+		})
+	}
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testSOCKS5Dialer)
+	}
+}
+
+func TestSOCKS5DialerErrors(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		username   string
+		password   string
+		reply      byte
+		dialerUser string
+		pipeline   bool
+		want       error
+	}{
+		{"Refused", "", "", 5, "", false, SOCKS5ReplyError(5)},
+		{"RefusedPipeline", "", "", 5, "", true, SOCKS5ReplyError(5)},
+		{"AuthFailed", "user", "pass", 0, "user", false, errSOCKS5AuthFailed},
+		{"AuthRequired", "user", "pass", 0, "", false, errSOCKS5NoAcceptableMethods},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := newSOCKS5TestServer(t, ListenConfig{}, c.username, c.password, c.reply)
+			defer s.Close()
+
+			sd := SOCKS5Dialer{
+				ProxyAddress: s.Addr(),
+				Username:     c.dialerUser,
+				Password:     "wrong",
+				Pipeline:     c.pipeline,
+			}
+			conn, err := sd.DialContext(t.Context(), "tcp", "192.0.2.1:443", hello)
+			if err == nil {
+				conn.Close()
+			}
+			if !errors.Is(err, c.want) {
+				t.Errorf("err = %v, want %v", err, c.want)
+			}
+		})
+	}
+}
+
+func TestSOCKS5DialerBadAuthVersion(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Select username/password authentication, and reply to it with a bad version.
+		if _, err := conn.Write([]byte{socks5Version, socks5MethodUsernamePass, 0, 0}); err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	sd := SOCKS5Dialer{
+		ProxyAddress: ln.Addr().String(),
+		Username:     "user",
+		Password:     "pass",
+	}
+	conn, err := sd.DialContext(t.Context(), "tcp", "192.0.2.1:443", hello)
+	if err == nil {
+		conn.Close()
+	}
+	if !errors.Is(err, errSOCKS5BadVersion) {
+		t.Errorf("err = %v, want %v", err, errSOCKS5BadVersion)
+	}
+}
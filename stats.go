@@ -0,0 +1,236 @@
+package tfo
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// dialLatencyBounds are the upper bounds of the buckets of [Stats.DialLatency].
+var dialLatencyBounds = [...]time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// stats holds the package-wide counters reported by [ReadStats].
+var stats struct {
+	dialAttempts  atomic.Uint64
+	dialErrors    atomic.Uint64
+	dials         [DialPathConnectEx + 1]atomic.Uint64
+	fallbacks     [FallbackReasonMultipathTCP + 1]atomic.Uint64
+	synBytes      atomic.Uint64
+	latencyCounts [len(dialLatencyBounds) + 1]atomic.Uint64
+	latencySum    atomic.Int64
+	tfoAccepts    atomic.Uint64
+	plainAccepts  atomic.Uint64
+}
+
+// Stats is a snapshot of the package-wide counters, aggregated over all [Dialer] dial calls
+// that attempted TFO with a non-empty payload, and all connections accepted by [TCPListener].
+// Dial calls with [Dialer.DisableTFO] set are not counted.
+type Stats struct {
+	// DialAttempts is the number of dial calls that attempted TFO.
+	// A [Dialer.DialAddrPorts] call counts as one dial call, however many addresses it attempts.
+	DialAttempts uint64
+
+	// DialErrors is the number of dial calls that attempted TFO and failed.
+	DialErrors uint64
+
+	// Dials is the number of connections that sent their initial payload, by [DialPath].
+	// Only connections returned by dial calls are counted.
+	Dials DialPathCounts
+
+	// Fallbacks is the number of fallbacks, by [FallbackReason].
+	Fallbacks FallbackCounts
+
+	// SYNBytes is the total number of payload bytes accepted by the kernel
+	// in the calls that initiated the handshakes. See [TFOInfo.SYNBytes].
+	SYNBytes uint64
+
+	// DialLatency is the latency distribution of successful dial calls that attempted TFO,
+	// including the time spent resolving names and sending the initial payload.
+	DialLatency LatencyHistogram
+
+	// TFOAccepts is the number of accepted connections whose SYN carried data.
+	TFOAccepts uint64
+
+	// PlainAccepts is the number of accepted connections whose SYN did not carry data.
+	PlainAccepts uint64
+}
+
+// DialPathCounts holds a counter for each [DialPath].
+type DialPathCounts struct {
+	// Plain counts connections established without TFO, because the dial call fell back.
+	Plain         uint64
+	ConnectOption uint64
+	Sendmsg       uint64
+	Connectx      uint64
+	ConnectEx     uint64
+}
+
+// FallbackCounts holds a counter for each [FallbackReason].
+type FallbackCounts struct {
+	CachedNoTFO    uint64
+	CachedSendto   uint64
+	Sendto         uint64
+	Unsupported    uint64
+	Blackholed     uint64
+	BlackholeRetry uint64
+	MultipathTCP   uint64
+}
+
+// LatencyHistogram is a latency distribution.
+type LatencyHistogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in ascending order.
+	Bounds []time.Duration
+
+	// Counts are the number of samples in each bucket. It has one more element than Bounds,
+	// which counts the samples above the last bound.
+	Counts []uint64
+
+	// Sum is the sum of all samples.
+	Sum time.Duration
+}
+
+// ReadStats returns a snapshot of the package-wide counters.
+func ReadStats() Stats {
+	s := Stats{
+		DialAttempts: stats.dialAttempts.Load(),
+		DialErrors:   stats.dialErrors.Load(),
+		Dials: DialPathCounts{
+			Plain:         stats.dials[DialPathPlain].Load(),
+			ConnectOption: stats.dials[DialPathConnectOption].Load(),
+			Sendmsg:       stats.dials[DialPathSendmsg].Load(),
+			Connectx:      stats.dials[DialPathConnectx].Load(),
+			ConnectEx:     stats.dials[DialPathConnectEx].Load(),
+		},
+		Fallbacks: FallbackCounts{
+			CachedNoTFO:    stats.fallbacks[FallbackReasonCachedNoTFO].Load(),
+			CachedSendto:   stats.fallbacks[FallbackReasonCachedSendto].Load(),
+			Sendto:         stats.fallbacks[FallbackReasonSendto].Load(),
+			Unsupported:    stats.fallbacks[FallbackReasonUnsupported].Load(),
+			Blackholed:     stats.fallbacks[FallbackReasonBlackholed].Load(),
+			BlackholeRetry: stats.fallbacks[FallbackReasonBlackholeRetry].Load(),
+			MultipathTCP:   stats.fallbacks[FallbackReasonMultipathTCP].Load(),
+		},
+		SYNBytes: stats.synBytes.Load(),
+		DialLatency: LatencyHistogram{
+			Bounds: dialLatencyBounds[:],
+			Counts: make([]uint64, len(stats.latencyCounts)),
+			Sum:    time.Duration(stats.latencySum.Load()),
+		},
+		TFOAccepts:   stats.tfoAccepts.Load(),
+		PlainAccepts: stats.plainAccepts.Load(),
+	}
+	for i := range stats.latencyCounts {
+		s.DialLatency.Counts[i] = stats.latencyCounts[i].Load()
+	}
+	return s
+}
+
+// StatsVar implements the expvar.Var interface by reporting [ReadStats] as JSON.
+// To publish the counters, call:
+//
+//	expvar.Publish("tfo", tfo.StatsVar{})
+type StatsVar struct{}
+
+// String implements expvar.Var.
+func (StatsVar) String() string {
+	b, err := json.Marshal(ReadStats())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+type noStatsContextKey struct{}
+
+// withoutStats returns a new context based on ctx, whose dial calls are not recorded
+// in the package-wide counters.
+func withoutStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noStatsContextKey{}, struct{}{})
+}
+
+// statsEnabled returns whether dial calls with ctx are recorded in the package-wide counters.
+func statsEnabled(ctx context.Context) bool {
+	return ctx.Value(noStatsContextKey{}) == nil
+}
+
+type nestedDialContextKey struct{}
+
+// withNestedDial returns a new context based on ctx, for dial calls made on behalf of another dial call,
+// which records the outcome of all of them once.
+func withNestedDial(ctx context.Context) context.Context {
+	return context.WithValue(ctx, nestedDialContextKey{}, struct{}{})
+}
+
+// recordDialCall records the outcome of a dial call that started at start, and the payload sent
+// by c, the returned connection, unless the dial call was made on behalf of another dial call.
+func recordDialCall(ctx context.Context, start time.Time, c *net.TCPConn, err error) {
+	if ctx.Value(nestedDialContextKey{}) != nil {
+		return
+	}
+	recordDial(ctx, start, err)
+	if c != nil {
+		reportPayloadSent(ctx, c)
+	}
+}
+
+// recordDial records the outcome of a dial call that attempted TFO, which started at start.
+func recordDial(ctx context.Context, start time.Time, err error) {
+	if !statsEnabled(ctx) {
+		return
+	}
+	stats.dialAttempts.Add(1)
+	if err != nil {
+		stats.dialErrors.Add(1)
+		return
+	}
+	latency := time.Since(start)
+	i := 0
+	for i < len(dialLatencyBounds) && latency > dialLatencyBounds[i] {
+		i++
+	}
+	stats.latencyCounts[i].Add(1)
+	stats.latencySum.Add(int64(latency))
+}
+
+// recordPayloadSent records a connection that sent its initial payload as described by info.
+func recordPayloadSent(ctx context.Context, info TFOInfo) {
+	if !statsEnabled(ctx) {
+		return
+	}
+	if int(info.Path) < len(stats.dials) {
+		stats.dials[info.Path].Add(1)
+	}
+	stats.synBytes.Add(uint64(info.SYNBytes))
+}
+
+// recordAccept records an accepted connection.
+func recordAccept(info AcceptInfo) {
+	if info.SYNData {
+		stats.tfoAccepts.Add(1)
+	} else {
+		stats.plainAccepts.Add(1)
+	}
+}
+
+// reportFallback records a fallback, and reports it to the [DialTrace] of ctx.
+func reportFallback(ctx context.Context, reason FallbackReason, err error) {
+	if int(reason) < len(stats.fallbacks) && statsEnabled(ctx) {
+		stats.fallbacks[reason].Add(1)
+	}
+	ContextDialTrace(ctx).fallback(reason, err)
+}
@@ -0,0 +1,189 @@
+package tfo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/netip"
+	"syscall"
+	"testing"
+)
+
+func sumCounts(counts []uint64) (n uint64) {
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
+
+func TestStats(t *testing.T) {
+	if comptimeDialNoTFO {
+		t.Skip("TFO is not supported on the current platform")
+	}
+
+	lc := ListenConfig{DisableTFO: comptimeListenNoTFO}
+	ln, err := lc.ListenTCP(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		readUntilEOF(conn, hello, t)
+	}()
+
+	before := ReadStats()
+
+	d := Dialer{Fallback: true, SupportCache: new(SupportCache)}
+	c, err := d.DialContext(t.Context(), "tcp", ln.Addr().String(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	info, _ := ConnTFOInfo(c)
+	c.(*net.TCPConn).CloseWrite()
+	<-done
+
+	after := ReadStats()
+	t.Logf("info: %+v", info)
+	t.Logf("stats: %+v", after)
+
+	// Other tests may leave dials in flight, so only check lower bounds.
+	if n := after.DialAttempts - before.DialAttempts; n < 1 {
+		t.Errorf("DialAttempts increased by %d, want >= 1", n)
+	}
+	var beforePath, afterPath uint64
+	switch info.Path {
+	case DialPathPlain:
+		beforePath, afterPath = before.Dials.Plain, after.Dials.Plain
+	case DialPathConnectOption:
+		beforePath, afterPath = before.Dials.ConnectOption, after.Dials.ConnectOption
+	case DialPathSendmsg:
+		beforePath, afterPath = before.Dials.Sendmsg, after.Dials.Sendmsg
+	case DialPathConnectx:
+		beforePath, afterPath = before.Dials.Connectx, after.Dials.Connectx
+	case DialPathConnectEx:
+		beforePath, afterPath = before.Dials.ConnectEx, after.Dials.ConnectEx
+	}
+	if n := afterPath - beforePath; n < 1 {
+		t.Errorf("Dials for path %v increased by %d, want >= 1", info.Path, n)
+	}
+	if n := after.SYNBytes - before.SYNBytes; n < uint64(info.SYNBytes) {
+		t.Errorf("SYNBytes increased by %d, want >= %d", n, info.SYNBytes)
+	}
+	if n := sumCounts(after.DialLatency.Counts) - sumCounts(before.DialLatency.Counts); n < 1 {
+		t.Errorf("DialLatency samples increased by %d, want >= 1", n)
+	}
+	if len(after.DialLatency.Counts) != len(after.DialLatency.Bounds)+1 {
+		t.Errorf("len(DialLatency.Counts) = %d, want %d", len(after.DialLatency.Counts), len(after.DialLatency.Bounds)+1)
+	}
+	if n := after.TFOAccepts + after.PlainAccepts - before.TFOAccepts - before.PlainAccepts; n < 1 {
+		t.Errorf("accepts increased by %d, want >= 1", n)
+	}
+
+	// StatsVar must report valid JSON for expvar.
+	var s Stats
+	if err = json.Unmarshal([]byte(StatsVar{}.String()), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.DialAttempts < after.DialAttempts {
+		t.Errorf("StatsVar DialAttempts = %d, want >= %d", s.DialAttempts, after.DialAttempts)
+	}
+}
+
+func TestStatsFallback(t *testing.T) {
+	if comptimeDialNoTFO {
+		t.Skip("TFO is not supported on the current platform")
+	}
+
+	s, err := newDiscardTCPServer(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	before := ReadStats()
+
+	d := Dialer{Fallback: true, SupportCache: new(SupportCache)}
+	runtimeFallbackSetDialNoTFO(d.SupportCache)
+	c, err := d.DialContext(t.Context(), "tcp", s.AddrPort().String(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	after := ReadStats()
+	if n := after.Fallbacks.CachedNoTFO - before.Fallbacks.CachedNoTFO; n < 1 {
+		t.Errorf("Fallbacks.CachedNoTFO increased by %d, want >= 1", n)
+	}
+	if n := after.Dials.Plain - before.Dials.Plain; n < 1 {
+		t.Errorf("Dials.Plain increased by %d, want >= 1", n)
+	}
+	if n := after.DialAttempts - before.DialAttempts; n < 1 {
+		t.Errorf("DialAttempts increased by %d, want >= 1", n)
+	}
+}
+
+// TestStatsDialAddrPorts ensures that a [Dialer.DialAddrPorts] call is counted once,
+// however many addresses it attempts.
+func TestStatsDialAddrPorts(t *testing.T) {
+	if comptimeDialNoTFO {
+		t.Skip("TFO is not supported on the current platform")
+	}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// The IPv6 address is attempted first.
+	raddr := ln.Addr().(*net.TCPAddr).AddrPort()
+	failed := netip.AddrPortFrom(netip.IPv6Loopback(), raddr.Port())
+	errFailed := errors.New("failed attempt")
+
+	d := Dialer{Fallback: true, SupportCache: new(SupportCache)}
+	d.ControlContext = func(_ context.Context, _, address string, _ syscall.RawConn) error {
+		if address == failed.String() {
+			return errFailed
+		}
+		return nil
+	}
+
+	var r traceRecorder
+	before := ReadStats()
+	c, err := d.DialAddrPorts(WithDialTrace(t.Context(), r.trace()), "tcp", []netip.AddrPort{failed, raddr}, hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	after := ReadStats()
+
+	if n := after.DialAttempts - before.DialAttempts; n != 1 {
+		t.Errorf("DialAttempts increased by %d, want 1", n)
+	}
+	if n := after.DialErrors - before.DialErrors; n != 0 {
+		t.Errorf("DialErrors increased by %d, want 0", n)
+	}
+	if len(r.infos) != 1 {
+		t.Errorf("PayloadSent infos = %+v, want 1", r.infos)
+	}
+}
@@ -0,0 +1,131 @@
+package tfo
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type dialTFOSupport uint32
+
+const (
+	dialTFOSupportDefault dialTFOSupport = iota
+	dialTFOSupportNone
+	dialTFOSupportLinuxSendto
+)
+
+// supportObservation is an observation of TFO support, made at a point in time.
+type supportObservation struct {
+	dial dialTFOSupport
+	at   time.Time
+}
+
+// SupportCache remembers the lack of TFO support observed by dial and listen calls
+// with Fallback set, so that subsequent calls skip TFO, or the unsupported code path,
+// without trying again.
+//
+// Dialers and listen configs share [DefaultSupportCache], unless their SupportCache field is set.
+// Observations are remembered until [SupportCache.Reset] is called, or until they are
+// older than [SupportCache.TTL], so that long-running processes pick up system configuration
+// changes, such as a fixed sysctl, without restarting.
+//
+// The zero value is ready for use. A SupportCache is safe for concurrent use.
+// Its TTL field must not be modified after first use.
+type SupportCache struct {
+	// TTL is how long an observation is remembered.
+	// If zero or negative, observations are remembered until reset.
+	TTL time.Duration
+
+	dial   atomic.Pointer[supportObservation]
+	listen atomic.Pointer[supportObservation]
+}
+
+// DefaultSupportCache is the [SupportCache] used by dialers and listen configs
+// whose SupportCache field is nil.
+var DefaultSupportCache SupportCache
+
+// SupportState is a snapshot of a [SupportCache].
+type SupportState struct {
+	// DialNoTFO reports whether dial calls with Fallback set skip TFO.
+	DialNoTFO bool
+
+	// DialSendto reports whether dial calls with Fallback set use sendto(MSG_FASTOPEN)
+	// instead of TCP_FASTOPEN_CONNECT, which was found unsupported (Linux).
+	DialSendto bool
+
+	// DialObservedAt is when the dial state was last changed.
+	// It is zero if no lack of support has been observed.
+	DialObservedAt time.Time
+
+	// ListenNoTFO reports whether listen calls with Fallback set skip TFO.
+	ListenNoTFO bool
+
+	// ListenObservedAt is when the listen state was last changed.
+	// It is zero if no lack of support has been observed.
+	ListenObservedAt time.Time
+}
+
+// supportCacheOrDefault returns c, or [DefaultSupportCache] if c is nil.
+func supportCacheOrDefault(c *SupportCache) *SupportCache {
+	if c != nil {
+		return c
+	}
+	return &DefaultSupportCache
+}
+
+// load returns the unexpired observation in p, or nil.
+func (c *SupportCache) load(p *atomic.Pointer[supportObservation]) *supportObservation {
+	o := p.Load()
+	if o != nil && c.TTL > 0 && time.Since(o.at) >= c.TTL {
+		p.CompareAndSwap(o, nil)
+		return nil
+	}
+	return o
+}
+
+func (c *SupportCache) loadDial() dialTFOSupport {
+	if o := c.load(&c.dial); o != nil {
+		return o.dial
+	}
+	return dialTFOSupportDefault
+}
+
+func (c *SupportCache) storeDial(s dialTFOSupport) {
+	c.dial.Store(&supportObservation{dial: s, at: time.Now()})
+}
+
+// casDial changes the dial state to new, if it is old.
+func (c *SupportCache) casDial(old, new dialTFOSupport) bool {
+	o := c.load(&c.dial)
+	if o == nil && old != dialTFOSupportDefault || o != nil && o.dial != old {
+		return false
+	}
+	return c.dial.CompareAndSwap(o, &supportObservation{dial: new, at: time.Now()})
+}
+
+func (c *SupportCache) loadListenNoTFO() bool {
+	return c.load(&c.listen) != nil
+}
+
+func (c *SupportCache) storeListenNoTFO() {
+	c.listen.Store(&supportObservation{at: time.Now()})
+}
+
+// State returns a snapshot of the cache.
+func (c *SupportCache) State() (s SupportState) {
+	if o := c.load(&c.dial); o != nil {
+		s.DialNoTFO = o.dial == dialTFOSupportNone
+		s.DialSendto = o.dial == dialTFOSupportLinuxSendto
+		s.DialObservedAt = o.at
+	}
+	if o := c.load(&c.listen); o != nil {
+		s.ListenNoTFO = true
+		s.ListenObservedAt = o.at
+	}
+	return s
+}
+
+// Reset forgets all observations, so that the next calls try TFO again.
+func (c *SupportCache) Reset() {
+	c.dial.Store(nil)
+	c.listen.Store(nil)
+}
@@ -0,0 +1,91 @@
+package tfo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSupportCache(t *testing.T) {
+	var c SupportCache
+	if s := c.State(); s != (SupportState{}) {
+		t.Errorf("State() = %+v, want zero value", s)
+	}
+
+	if !c.casDial(dialTFOSupportDefault, dialTFOSupportLinuxSendto) {
+		t.Error("casDial(Default, LinuxSendto) = false, want true")
+	}
+	if c.casDial(dialTFOSupportDefault, dialTFOSupportNone) {
+		t.Error("casDial(Default, None) = true after LinuxSendto, want false")
+	}
+	if s := c.State(); !s.DialSendto || s.DialNoTFO || s.DialObservedAt.IsZero() {
+		t.Errorf("State() = %+v, want DialSendto", s)
+	}
+
+	c.storeDial(dialTFOSupportNone)
+	c.storeListenNoTFO()
+	if s := c.State(); !s.DialNoTFO || s.DialSendto || !s.ListenNoTFO || s.ListenObservedAt.IsZero() {
+		t.Errorf("State() = %+v, want DialNoTFO and ListenNoTFO", s)
+	}
+
+	c.Reset()
+	if s := c.State(); s != (SupportState{}) {
+		t.Errorf("State() = %+v after Reset, want zero value", s)
+	}
+}
+
+func TestSupportCacheTTL(t *testing.T) {
+	c := SupportCache{TTL: 20 * time.Millisecond}
+	c.storeDial(dialTFOSupportNone)
+	c.storeListenNoTFO()
+
+	if c.loadDial() != dialTFOSupportNone || !c.loadListenNoTFO() {
+		t.Fatal("observations expired too early")
+	}
+
+	time.Sleep(2 * c.TTL)
+
+	if got := c.loadDial(); got != dialTFOSupportDefault {
+		t.Errorf("loadDial() = %v after TTL, want %v", got, dialTFOSupportDefault)
+	}
+	if c.loadListenNoTFO() {
+		t.Error("loadListenNoTFO() = true after TTL, want false")
+	}
+	if !c.casDial(dialTFOSupportDefault, dialTFOSupportLinuxSendto) {
+		t.Error("casDial(Default, LinuxSendto) = false after TTL, want true")
+	}
+}
+
+// TestSupportCacheScope ensures that observations in one [SupportCache] do not affect
+// dialers and listen configs using another.
+func TestSupportCacheScope(t *testing.T) {
+	if comptimeDialNoTFO || comptimeListenNoTFO {
+		t.Skip("not applicable to the current platform")
+	}
+
+	var c1, c2 SupportCache
+	c1.storeDial(dialTFOSupportNone)
+	c1.storeListenNoTFO()
+
+	d1 := Dialer{Fallback: true, SupportCache: &c1}
+	d2 := Dialer{Fallback: true, SupportCache: &c2}
+	if d1.TFO() {
+		t.Error("d1.TFO() = true, want false")
+	}
+	if !d2.TFO() {
+		t.Error("d2.TFO() = false, want true")
+	}
+
+	lc1 := ListenConfig{Fallback: true, SupportCache: &c1}
+	lc2 := ListenConfig{Fallback: true, SupportCache: &c2}
+	if lc1.TFO() {
+		t.Error("lc1.TFO() = true, want false")
+	}
+	if !lc2.TFO() {
+		t.Error("lc2.TFO() = false, want true")
+	}
+
+	c1.Reset()
+	if !d1.TFO() || !lc1.TFO() {
+		t.Error("TFO() = false after Reset, want true")
+	}
+}
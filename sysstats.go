@@ -0,0 +1,136 @@
+package tfo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SystemCounters is a snapshot of the system-wide TFO counters,
+// as reported in the TcpExt section of /proc/net/netstat on Linux.
+//
+// Counters not supported by the running kernel are zero.
+type SystemCounters struct {
+	// Active is TCPFastOpenActive, the number of SYNs with data sent with a valid cookie.
+	Active uint64
+
+	// ActiveFail is TCPFastOpenActiveFail, the number of SYNs with data that were not acknowledged,
+	// or timed out.
+	ActiveFail uint64
+
+	// Passive is TCPFastOpenPassive, the number of SYNs with data accepted.
+	Passive uint64
+
+	// PassiveFail is TCPFastOpenPassiveFail, the number of SYNs with data rejected
+	// because of an invalid cookie.
+	PassiveFail uint64
+
+	// ListenOverflow is TCPFastOpenListenOverflow, the number of SYNs with data rejected
+	// because the TFO queue of the listener was full.
+	ListenOverflow uint64
+
+	// CookieReqd is TCPFastOpenCookieReqd, the number of SYNs requesting a cookie.
+	CookieReqd uint64
+
+	// Blackhole is TCPFastOpenBlackhole, the number of times active TFO was disabled
+	// because of suspected middlebox blackholing.
+	Blackhole uint64
+
+	// PassiveAltKey is TCPFastOpenPassiveAltKey, the number of SYNs with data accepted
+	// with a cookie issued by the backup key.
+	PassiveAltKey uint64
+}
+
+// Sub returns the difference between s and prev, counter by counter.
+// It is useful for computing the counter increments between two snapshots.
+func (s SystemCounters) Sub(prev SystemCounters) SystemCounters {
+	return SystemCounters{
+		Active:         s.Active - prev.Active,
+		ActiveFail:     s.ActiveFail - prev.ActiveFail,
+		Passive:        s.Passive - prev.Passive,
+		PassiveFail:    s.PassiveFail - prev.PassiveFail,
+		ListenOverflow: s.ListenOverflow - prev.ListenOverflow,
+		CookieReqd:     s.CookieReqd - prev.CookieReqd,
+		Blackhole:      s.Blackhole - prev.Blackhole,
+		PassiveAltKey:  s.PassiveAltKey - prev.PassiveAltKey,
+	}
+}
+
+// counter returns a pointer to the field of s for the named TcpExt counter, or nil if unknown.
+func (s *SystemCounters) counter(name string) *uint64 {
+	switch name {
+	case "TCPFastOpenActive":
+		return &s.Active
+	case "TCPFastOpenActiveFail":
+		return &s.ActiveFail
+	case "TCPFastOpenPassive":
+		return &s.Passive
+	case "TCPFastOpenPassiveFail":
+		return &s.PassiveFail
+	case "TCPFastOpenListenOverflow":
+		return &s.ListenOverflow
+	case "TCPFastOpenCookieReqd":
+		return &s.CookieReqd
+	case "TCPFastOpenBlackhole":
+		return &s.Blackhole
+	case "TCPFastOpenPassiveAltKey":
+		return &s.PassiveAltKey
+	default:
+		return nil
+	}
+}
+
+var errNoTCPExt = errors.New("TcpExt section not found")
+
+// parseNetstat parses the TFO counters from the contents of /proc/net/netstat.
+//
+// The file consists of pairs of lines, the first listing the counter names of a section,
+// and the second listing their values, both prefixed with the section name:
+//
+//	TcpExt: SyncookiesSent SyncookiesRecv ...
+//	TcpExt: 0 0 ...
+func parseNetstat(r io.Reader) (s SystemCounters, err error) {
+	var names []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || fields[0] != "TcpExt:" {
+			continue
+		}
+		if names == nil {
+			names = fields[1:]
+			continue
+		}
+
+		values := fields[1:]
+		if len(values) != len(names) {
+			return s, fmt.Errorf("TcpExt: %d names but %d values", len(names), len(values))
+		}
+		for i, name := range names {
+			p := s.counter(name)
+			if p == nil {
+				continue
+			}
+			if *p, err = strconv.ParseUint(values[i], 10, 64); err != nil {
+				return s, fmt.Errorf("TcpExt: %s: %w", name, err)
+			}
+		}
+		return s, nil
+	}
+	if err = sc.Err(); err != nil {
+		return s, err
+	}
+	return s, errNoTCPExt
+}
+
+// SystemStats returns a snapshot of the system-wide TFO counters.
+//
+// This is only supported on Linux, where the counters are read from /proc/net/netstat,
+// and reflect the network namespace of the calling process.
+func SystemStats() (SystemCounters, error) {
+	return systemStats() // sysstats_linux.go, sysstats_stub.go
+}
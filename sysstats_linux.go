@@ -0,0 +1,12 @@
+package tfo
+
+import "os"
+
+func systemStats() (SystemCounters, error) {
+	f, err := os.Open("/proc/net/netstat")
+	if err != nil {
+		return SystemCounters{}, err
+	}
+	defer f.Close()
+	return parseNetstat(f)
+}
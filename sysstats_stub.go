@@ -0,0 +1,7 @@
+//go:build !linux
+
+package tfo
+
+func systemStats() (SystemCounters, error) {
+	return SystemCounters{}, ErrPlatformUnsupported
+}
@@ -0,0 +1,63 @@
+package tfo
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const testNetstat = `TcpExt: SyncookiesSent TCPFastOpenActive TCPFastOpenActiveFail TCPFastOpenPassive TCPFastOpenPassiveFail TCPFastOpenListenOverflow TCPFastOpenCookieReqd TCPFastOpenBlackhole TCPFastOpenPassiveAltKey
+TcpExt: 1 2 3 4 5 6 7 8 9
+IpExt: InNoRoutes TCPFastOpenActive
+IpExt: 0 100
+`
+
+func TestParseNetstat(t *testing.T) {
+	s, err := parseNetstat(strings.NewReader(testNetstat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SystemCounters{
+		Active:         2,
+		ActiveFail:     3,
+		Passive:        4,
+		PassiveFail:    5,
+		ListenOverflow: 6,
+		CookieReqd:     7,
+		Blackhole:      8,
+		PassiveAltKey:  9,
+	}
+	if s != want {
+		t.Errorf("parseNetstat() = %+v, want %+v", s, want)
+	}
+
+	if diff := s.Sub(SystemCounters{Active: 1, PassiveAltKey: 9}); diff.Active != 1 || diff.PassiveAltKey != 0 || diff.CookieReqd != 7 {
+		t.Errorf("Sub() = %+v", diff)
+	}
+
+	for _, bad := range []string{
+		"",
+		"IpExt: InNoRoutes\nIpExt: 0\n",
+		"TcpExt: TCPFastOpenActive TCPFastOpenPassive\nTcpExt: 1\n",
+		"TcpExt: TCPFastOpenActive\nTcpExt: -1\n",
+	} {
+		if _, err := parseNetstat(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseNetstat(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestSystemStats(t *testing.T) {
+	s, err := SystemStats()
+	if runtime.GOOS != "linux" {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("SystemStats() error = %v, want %v", err, errors.ErrUnsupported)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("SystemStats() = %+v", s)
+}
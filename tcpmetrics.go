@@ -0,0 +1,69 @@
+package tfo
+
+import (
+	"net/netip"
+	"time"
+)
+
+// TCPMetricsEntry is an entry in the kernel's per-destination TCP metrics cache,
+// which also holds the TFO cookie cache on the client side.
+type TCPMetricsEntry struct {
+	// Addr is the destination address.
+	Addr netip.Addr
+
+	// SourceAddr is the source address, if the kernel reports one.
+	SourceAddr netip.Addr
+
+	// Age is the time since the entry was last updated.
+	Age time.Duration
+
+	// RTT is the cached smoothed round-trip time.
+	RTT time.Duration
+
+	// RTTVar is the cached round-trip time variance.
+	RTTVar time.Duration
+
+	// SSThresh is the cached slow start threshold.
+	SSThresh uint32
+
+	// Cwnd is the cached congestion window.
+	Cwnd uint32
+
+	// Reordering is the cached reordering metric.
+	Reordering uint32
+
+	// FastOpenMSS is the MSS advertised by the server, used to size data in SYN.
+	FastOpenMSS uint16
+
+	// FastOpenCookie is the TFO cookie for the destination, or nil if none is cached.
+	FastOpenCookie []byte
+
+	// FastOpenSYNDrops is the number of consecutive SYNs with data that were lost.
+	FastOpenSYNDrops uint16
+
+	// FastOpenSYNDropAge is the time since the last SYN with data was lost.
+	// It is zero if no SYN with data was lost.
+	FastOpenSYNDropAge time.Duration
+}
+
+// TCPMetrics returns the kernel's TCP metrics entries for the destination addr,
+// or all entries if addr is the zero value, using the tcp_metrics generic netlink family.
+// For a non-zero addr, the kernel looks up a single entry, and an empty slice is returned
+// if there is none.
+//
+// This is a client-side diagnostic for checking whether the kernel holds a TFO cookie
+// for a destination. Entries are for the network namespace of the calling thread.
+//
+// This is only supported on Linux.
+func TCPMetrics(addr netip.Addr) ([]TCPMetricsEntry, error) {
+	return tcpMetrics(addr) // tcpmetrics_linux.go, tcpmetrics_stub.go
+}
+
+// DeleteTCPMetrics deletes the kernel's TCP metrics entries for the destination addr,
+// including any cached TFO cookie, or all entries if addr is the zero value.
+//
+// This requires CAP_NET_ADMIN in the network namespace.
+// This is only supported on Linux.
+func DeleteTCPMetrics(addr netip.Addr) error {
+	return deleteTCPMetrics(addr) // tcpmetrics_linux.go, tcpmetrics_stub.go
+}
@@ -0,0 +1,325 @@
+package tfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// From include/uapi/linux/tcp_metrics.h
+const (
+	tcpMetricsGenlName    = "tcp_metrics"
+	tcpMetricsGenlVersion = 0x1
+
+	tcpMetricRTT        = 0
+	tcpMetricRTTVar     = 1
+	tcpMetricSSThresh   = 2
+	tcpMetricCwnd       = 3
+	tcpMetricReordering = 4
+	tcpMetricRTTUs      = 5
+	tcpMetricRTTVarUs   = 6
+
+	tcpMetricsAttrUnspec         = 0
+	tcpMetricsAttrAddrIPv4       = 1
+	tcpMetricsAttrAddrIPv6       = 2
+	tcpMetricsAttrAge            = 3
+	tcpMetricsAttrTWTSVal        = 4
+	tcpMetricsAttrTWTSStamp      = 5
+	tcpMetricsAttrVals           = 6
+	tcpMetricsAttrFOpenMSS       = 7
+	tcpMetricsAttrFOpenSYNDrops  = 8
+	tcpMetricsAttrFOpenSYNDropTS = 9
+	tcpMetricsAttrFOpenCookie    = 10
+	tcpMetricsAttrSAddrIPv4      = 11
+	tcpMetricsAttrSAddrIPv6      = 12
+
+	tcpMetricsCmdUnspec = 0
+	tcpMetricsCmdGet    = 1
+	tcpMetricsCmdDel    = 2
+)
+
+// sizeofGenlMsghdr is the size of struct genlmsghdr.
+const sizeofGenlMsghdr = 4
+
+func tcpMetrics(addr netip.Addr) ([]TCPMetricsEntry, error) {
+	c, family, err := dialTCPMetrics()
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	// Dump the whole table, or look up the entry for addr.
+	var (
+		flags uint16 = unix.NLM_F_DUMP
+		attrs []byte
+	)
+	if addr.IsValid() {
+		flags = 0
+		attrs = appendTCPMetricsAddr(attrs, addr)
+	}
+
+	msgs, err := c.execute(family, tcpMetricsCmdGet, tcpMetricsGenlVersion, flags, attrs)
+	if err != nil {
+		if err == unix.ESRCH {
+			// No entry for addr.
+			return nil, nil
+		}
+		return nil, os.NewSyscallError("netlink(TCP_METRICS_CMD_GET)", err)
+	}
+
+	entries := make([]TCPMetricsEntry, len(msgs))
+	for i, msg := range msgs {
+		entries[i] = parseTCPMetricsEntry(msg)
+	}
+	return entries, nil
+}
+
+func deleteTCPMetrics(addr netip.Addr) error {
+	c, family, err := dialTCPMetrics()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	var attrs []byte
+	if addr.IsValid() {
+		attrs = appendTCPMetricsAddr(attrs, addr)
+	}
+	if _, err = c.execute(family, tcpMetricsCmdDel, tcpMetricsGenlVersion, 0, attrs); err != nil {
+		return os.NewSyscallError("netlink(TCP_METRICS_CMD_DEL)", err)
+	}
+	return nil
+}
+
+func appendTCPMetricsAddr(b []byte, addr netip.Addr) []byte {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		a := addr.As4()
+		return appendNlAttr(b, tcpMetricsAttrAddrIPv4, a[:])
+	}
+	a := addr.As16()
+	return appendNlAttr(b, tcpMetricsAttrAddrIPv6, a[:])
+}
+
+// tcpMetricsFamily caches the tcp_metrics family ID, or 0 if it has not been resolved.
+// Generic netlink family IDs are assigned when the family is registered, and are shared
+// by all network namespaces, so that the ID does not change once resolved.
+var tcpMetricsFamily atomic.Uint32
+
+// dialTCPMetrics opens a generic netlink socket, and resolves the tcp_metrics family ID,
+// unless it is cached.
+func dialTCPMetrics() (*genlConn, uint16, error) {
+	c, err := dialGenl()
+	if err != nil {
+		return nil, 0, err
+	}
+	if family := uint16(tcpMetricsFamily.Load()); family != 0 {
+		return c, family, nil
+	}
+	family, err := c.resolveFamily(tcpMetricsGenlName)
+	if err != nil {
+		c.close()
+		return nil, 0, err
+	}
+	tcpMetricsFamily.Store(uint32(family))
+	return c, family, nil
+}
+
+func parseTCPMetricsEntry(b []byte) (e TCPMetricsEntry) {
+	forEachNlAttr(b, func(typ uint16, data []byte) {
+		switch typ {
+		case tcpMetricsAttrAddrIPv4, tcpMetricsAttrAddrIPv6:
+			e.Addr, _ = netip.AddrFromSlice(data)
+		case tcpMetricsAttrSAddrIPv4, tcpMetricsAttrSAddrIPv6:
+			e.SourceAddr, _ = netip.AddrFromSlice(data)
+		case tcpMetricsAttrAge:
+			e.Age = time.Duration(nlAttrUint(data)) * time.Millisecond
+		case tcpMetricsAttrVals:
+			var rttUs, rttVarUs bool
+			forEachNlAttr(data, func(typ uint16, data []byte) {
+				v := nlAttrUint(data)
+				switch typ - 1 {
+				case tcpMetricRTT:
+					if !rttUs {
+						e.RTT = time.Duration(v) * time.Millisecond
+					}
+				case tcpMetricRTTVar:
+					if !rttVarUs {
+						e.RTTVar = time.Duration(v) * time.Millisecond
+					}
+				case tcpMetricSSThresh:
+					e.SSThresh = uint32(v)
+				case tcpMetricCwnd:
+					e.Cwnd = uint32(v)
+				case tcpMetricReordering:
+					e.Reordering = uint32(v)
+				case tcpMetricRTTUs:
+					e.RTT, rttUs = time.Duration(v)*time.Microsecond, true
+				case tcpMetricRTTVarUs:
+					e.RTTVar, rttVarUs = time.Duration(v)*time.Microsecond, true
+				}
+			})
+		case tcpMetricsAttrFOpenMSS:
+			e.FastOpenMSS = uint16(nlAttrUint(data))
+		case tcpMetricsAttrFOpenSYNDrops:
+			e.FastOpenSYNDrops = uint16(nlAttrUint(data))
+		case tcpMetricsAttrFOpenSYNDropTS:
+			e.FastOpenSYNDropAge = time.Duration(nlAttrUint(data)) * time.Millisecond
+		case tcpMetricsAttrFOpenCookie:
+			e.FastOpenCookie = append([]byte(nil), data...)
+		}
+	})
+	return e
+}
+
+// genlConn is a minimal generic netlink client.
+type genlConn struct {
+	fd  int
+	seq uint32
+}
+
+func dialGenl() (*genlConn, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_GENERIC)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	return &genlConn{fd: fd}, nil
+}
+
+func (c *genlConn) close() error {
+	return unix.Close(c.fd)
+}
+
+// resolveFamily returns the ID of the named generic netlink family.
+func (c *genlConn) resolveFamily(name string) (uint16, error) {
+	attrs := appendNlAttr(nil, unix.CTRL_ATTR_FAMILY_NAME, append([]byte(name), 0))
+	msgs, err := c.execute(unix.GENL_ID_CTRL, unix.CTRL_CMD_GETFAMILY, 1, 0, attrs)
+	if err != nil {
+		return 0, os.NewSyscallError("netlink(CTRL_CMD_GETFAMILY)", err)
+	}
+	var id uint16
+	for _, msg := range msgs {
+		forEachNlAttr(msg, func(typ uint16, data []byte) {
+			if typ == unix.CTRL_ATTR_FAMILY_ID {
+				id = uint16(nlAttrUint(data))
+			}
+		})
+	}
+	if id == 0 {
+		return 0, errors.New("generic netlink family not found: " + name)
+	}
+	return id, nil
+}
+
+// execute sends a request, and returns the attributes of all response messages,
+// with the netlink and generic netlink headers removed.
+func (c *genlConn) execute(family uint16, cmd, version uint8, flags uint16, attrs []byte) ([][]byte, error) {
+	c.seq++
+	seq := c.seq
+	flags |= unix.NLM_F_REQUEST | unix.NLM_F_ACK
+
+	b := make([]byte, unix.NLMSG_HDRLEN, unix.NLMSG_HDRLEN+sizeofGenlMsghdr+len(attrs))
+	b = append(b, cmd, version, 0, 0)
+	b = append(b, attrs...)
+	binary.NativeEndian.PutUint32(b[0:4], uint32(len(b)))
+	binary.NativeEndian.PutUint16(b[4:6], family)
+	binary.NativeEndian.PutUint16(b[6:8], flags)
+	binary.NativeEndian.PutUint32(b[8:12], seq)
+
+	if err := unix.Sendto(c.fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	var (
+		replies [][]byte
+		buf     = make([]byte, 64*1024)
+	)
+	for {
+		n, _, err := unix.Recvfrom(c.fd, buf, 0)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return nil, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if msg.Header.Seq != seq {
+				continue
+			}
+			switch msg.Header.Type {
+			case unix.NLMSG_DONE:
+				return replies, nil
+			case unix.NLMSG_ERROR:
+				if len(msg.Data) < 4 {
+					return nil, unix.EBADMSG
+				}
+				if errno := -int32(binary.NativeEndian.Uint32(msg.Data)); errno != 0 {
+					return nil, syscall.Errno(errno)
+				}
+				// An acknowledgement ends a non-dump request.
+				return replies, nil
+			default:
+				if len(msg.Data) >= sizeofGenlMsghdr {
+					// Copy, as buf is reused by the next read.
+					replies = append(replies, append([]byte(nil), msg.Data[sizeofGenlMsghdr:]...))
+				}
+			}
+		}
+	}
+}
+
+func nlAttrAlign(n int) int {
+	return (n + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+}
+
+// appendNlAttr appends a netlink attribute to b.
+func appendNlAttr(b []byte, typ uint16, data []byte) []byte {
+	n := unix.NLA_HDRLEN + len(data)
+	b = binary.NativeEndian.AppendUint16(b, uint16(n))
+	b = binary.NativeEndian.AppendUint16(b, typ)
+	b = append(b, data...)
+	return append(b, make([]byte, nlAttrAlign(n)-n)...)
+}
+
+// forEachNlAttr calls fn for each netlink attribute in b.
+func forEachNlAttr(b []byte, fn func(typ uint16, data []byte)) {
+	for len(b) >= unix.NLA_HDRLEN {
+		n := int(binary.NativeEndian.Uint16(b[0:2]))
+		typ := binary.NativeEndian.Uint16(b[2:4]) &^ (unix.NLA_F_NESTED | unix.NLA_F_NET_BYTEORDER)
+		if n < unix.NLA_HDRLEN || n > len(b) {
+			return
+		}
+		fn(typ, b[unix.NLA_HDRLEN:n])
+		b = b[min(nlAttrAlign(n), len(b)):]
+	}
+}
+
+// nlAttrUint decodes an unsigned integer attribute of 1, 2, 4, or 8 bytes.
+func nlAttrUint(data []byte) uint64 {
+	switch len(data) {
+	case 1:
+		return uint64(data[0])
+	case 2:
+		return uint64(binary.NativeEndian.Uint16(data))
+	case 4:
+		return uint64(binary.NativeEndian.Uint32(data))
+	case 8:
+		return binary.NativeEndian.Uint64(data)
+	default:
+		return 0
+	}
+}
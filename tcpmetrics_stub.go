@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tfo
+
+import "net/netip"
+
+func tcpMetrics(_ netip.Addr) ([]TCPMetricsEntry, error) {
+	return nil, ErrPlatformUnsupported
+}
+
+func deleteTCPMetrics(_ netip.Addr) error {
+	return ErrPlatformUnsupported
+}
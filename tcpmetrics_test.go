@@ -0,0 +1,99 @@
+package tfo
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestTCPMetrics(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		if _, err := TCPMetrics(netip.Addr{}); err != ErrPlatformUnsupported {
+			t.Errorf("TCPMetrics error = %v, want %v", err, ErrPlatformUnsupported)
+		}
+		return
+	}
+
+	if _, err := TCPMetrics(netip.Addr{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Probe(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.ClientEnabled || !r.ServerEnabled {
+		t.Skip("TFO is not enabled for both client and server")
+	}
+
+	// Use a random loopback address, so that the metrics entry is ours alone.
+	var b [3]byte
+	rand.Read(b[:])
+	addr := netip.AddrFrom4([4]byte{127, b[0], b[1], b[2] | 1})
+
+	if entries, err := TCPMetrics(addr); err != nil || len(entries) != 0 {
+		t.Fatalf("TCPMetrics(%v) = %+v, %v, want no entries", addr, entries, err)
+	}
+
+	var lc ListenConfig
+	ln, err := lc.Listen(t.Context(), "tcp4", netip.AddrPortFrom(addr, 0).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			readUntilEOF(conn, hello, t)
+			conn.Close()
+		}
+	}()
+
+	// The first connection obtains a cookie.
+	var d Dialer
+	c, err := d.DialContext(t.Context(), "tcp", ln.Addr().String(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*net.TCPConn).CloseWrite()
+	readUntilEOF(c, nil, t)
+	c.Close()
+
+	entries, err := TCPMetrics(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 {
+		t.Fatalf("no metrics entry for %v", addr)
+	}
+	for _, e := range entries {
+		t.Logf("entry: %+v", e)
+		if e.Addr != addr {
+			t.Errorf("e.Addr = %v, want %v", e.Addr, addr)
+		}
+	}
+	if len(entries[0].FastOpenCookie) == 0 {
+		t.Error("no TFO cookie in metrics entry")
+	}
+
+	if err = DeleteTCPMetrics(addr); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+	if entries, err = TCPMetrics(addr); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("entries after delete: %+v", entries)
+	}
+}
@@ -12,9 +12,10 @@ package tfo
 import (
 	"context"
 	"errors"
+	"io"
 	"net"
 	"net/netip"
-	"sync/atomic"
+	"slices"
 	"time"
 )
 
@@ -34,8 +35,6 @@ func (PlatformUnsupportedError) Is(target error) bool {
 	return target == errors.ErrUnsupported
 }
 
-var runtimeListenNoTFO atomic.Bool
-
 // ListenConfig wraps [net.ListenConfig] with TFO-related options.
 type ListenConfig struct {
 	net.ListenConfig
@@ -45,6 +44,14 @@ type ListenConfig struct {
 	// If the value is negative, TFO is disabled.
 	Backlog int
 
+	// DeferAccept, if positive, sets TCP_DEFER_ACCEPT on the listener, so that Accept
+	// only returns a connection once data has arrived on it, or the timeout has elapsed.
+	// The timeout is rounded up to whole seconds. This applies even when TFO is disabled.
+	//
+	// This is only supported on Linux. If not supported, Listen fails,
+	// unless [ListenConfig.Fallback] is set, in which case the option is ignored.
+	DeferAccept time.Duration
+
 	// DisableTFO controls whether TCP Fast Open is disabled when the Listen method is called.
 	// TFO is enabled by default, unless [ListenConfig.Backlog] is negative.
 	// Set to true to disable TFO and it will behave exactly the same as [net.ListenConfig].
@@ -53,6 +60,34 @@ type ListenConfig struct {
 	// Fallback controls whether to proceed without TFO when TFO is enabled but not supported
 	// on the system.
 	Fallback bool
+
+	// NoCookie enables cookieless TFO with TCP_FASTOPEN_NO_COOKIE, so that data in SYN
+	// is accepted without a valid cookie. Only use it when all clients are trusted,
+	// as it exposes the server to SYN floods with data, and amplification attacks.
+	//
+	// This is only supported on Linux 4.15 and later. If not supported, Listen fails,
+	// unless [ListenConfig.Fallback] is set, in which case TFO proceeds with cookies.
+	NoCookie bool
+
+	// TFOKeys, if not empty, sets the TFO cookie keys of the listener.
+	// The first key is the primary key, and the optional second key is the backup key.
+	// See [SetTFOKeys] for details. Use [TFOKeyRotator] to derive keys from a shared secret.
+	//
+	// This is only supported on Linux. On other platforms, Listen fails when TFOKeys is set
+	// and TFO is enabled.
+	TFOKeys []TFOKey
+
+	// SocketOptions are socket options set on the listener before it starts listening.
+	// Most of them are inherited by accepted connections. See [SocketOptions] for details.
+	SocketOptions SocketOptions
+
+	// SupportCache, if not nil, is where Listen records and looks up the lack of TFO support
+	// when Fallback is set. If nil, [DefaultSupportCache] is used.
+	SupportCache *SupportCache
+
+	// NetNS, if not nil, is the Linux network namespace in which the listener is created.
+	// See [NetNS] for details.
+	NetNS *NetNS
 }
 
 func (lc *ListenConfig) tfoDisabled() bool {
@@ -60,7 +95,7 @@ func (lc *ListenConfig) tfoDisabled() bool {
 }
 
 func (lc *ListenConfig) tfoNeedsFallback() bool {
-	return lc.Fallback && (comptimeListenNoTFO || runtimeListenNoTFO.Load())
+	return lc.Fallback && (comptimeListenNoTFO || supportCacheOrDefault(lc.SupportCache).loadListenNoTFO())
 }
 
 // TFO returns true if the next Listen call will attempt to enable TFO.
@@ -71,9 +106,23 @@ func (lc *ListenConfig) TFO() bool {
 // Listen is like [net.ListenConfig.Listen] but enables TFO whenever possible,
 // unless [ListenConfig.Backlog] is negative or [ListenConfig.DisableTFO] is set to true.
 func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (net.Listener, error) {
+	if lc.NetNS != nil {
+		return inNetNS(lc.NetNS, func() (net.Listener, error) {
+			return lc.netNSCopy().Listen(ctx, network, address)
+		})
+	}
+	if lc.DeferAccept > 0 && networkIsTCP(network) {
+		return lc.withDeferAccept().Listen(ctx, network, address)
+	}
+	if !lc.SocketOptions.isZero() && networkIsTCP(network) {
+		return lc.withSocketOptions().Listen(ctx, network, address)
+	}
 	if lc.tfoDisabled() || !networkIsTCP(network) || lc.tfoNeedsFallback() {
 		return lc.ListenConfig.Listen(ctx, network, address)
 	}
+	if len(lc.TFOKeys) > maxTFOKeys {
+		return nil, &net.OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: errTFOKeyCount}
+	}
 	return lc.listenTFO(ctx, network, address) // tfo_darwin.go, tfo_listen_generic.go, tfo_listen_stub.go
 }
 
@@ -105,29 +154,17 @@ func ListenTCP(network string, laddr *net.TCPAddr) (*net.TCPListener, error) {
 	return ln.(*net.TCPListener), err
 }
 
-type dialTFOSupport uint32
-
-const (
-	dialTFOSupportDefault dialTFOSupport = iota
-	dialTFOSupportNone
-	dialTFOSupportLinuxSendto
-)
-
-type atomicDialTFOSupport struct {
-	v atomic.Uint32
-}
-
-func (a *atomicDialTFOSupport) load() dialTFOSupport {
-	return dialTFOSupport(a.v.Load())
-}
-
-func (a *atomicDialTFOSupport) storeNone() {
-	a.v.Store(uint32(dialTFOSupportNone))
-}
-
-var runtimeDialTFOSupport atomicDialTFOSupport
-
 // Dialer wraps [net.Dialer] with an additional option that allows you to disable TFO.
+//
+// The Control or ControlContext function of the embedded [net.Dialer] is called on every socket
+// the dial methods create, including the sockets this package creates itself for TFO.
+// Earlier versions of DialTCP did not call them on those sockets.
+//
+// With TFO enabled, the dial methods resolve names and race the addresses with Happy Eyeballs
+// Version 2 (RFC 8305) in this package, on all TFO paths, including the default TCP_FASTOPEN_CONNECT
+// path on Linux. Attempts that send the payload in the SYN are made sequentially, unless
+// [Dialer.RaceSYNData] is set. See [Dialer.DialAddrPorts] for details. With TFO disabled,
+// or after falling back to dialing without TFO, [net.Dialer] resolves and races the addresses.
 type Dialer struct {
 	net.Dialer
 
@@ -141,60 +178,157 @@ type Dialer struct {
 	// On Linux this also controls whether the sendto(MSG_FASTOPEN) fallback path is tried
 	// before giving up on TFO.
 	Fallback bool
+
+	// NoCookie enables cookieless TFO with TCP_FASTOPEN_NO_COOKIE, so that data is sent in SYN
+	// without first obtaining a cookie from the server. The server must accept data in SYN
+	// without a cookie, for example with [ListenConfig.NoCookie], or the data is retransmitted
+	// after the handshake.
+	//
+	// This is only supported on Linux 4.15 and later. If not supported, dialing fails,
+	// unless [Dialer.Fallback] is set, in which case TFO proceeds with cookies.
+	NoCookie bool
+
+	// BlackholeCache, if not nil, enables per-destination TFO blackhole detection.
+	// See [BlackholeCache] for details.
+	//
+	// On Linux, setting BlackholeCache makes the dialer use sendto(MSG_FASTOPEN) instead of
+	// TCP_FASTOPEN_CONNECT, so that each connection attempt can be handled individually.
+	BlackholeCache *BlackholeCache
+
+	// RaceSYNData controls whether connection attempts that send the payload in the SYN
+	// are raced like other attempts. When set, the payload may reach more than one server.
+	// By default, such attempts are made sequentially.
+	RaceSYNData bool
+
+	// SocketOptions are socket options set on dialed connections before the SYN is sent.
+	// See [SocketOptions] for details.
+	SocketOptions SocketOptions
+
+	// SupportCache, if not nil, is where dial calls record and look up the lack of TFO support
+	// when Fallback is set. If nil, [DefaultSupportCache] is used.
+	SupportCache *SupportCache
+
+	// NetNS, if not nil, is the Linux network namespace in which connections are created.
+	// See [NetNS] for details.
+	NetNS *NetNS
+
+	// disableNoDelay is [SocketOptions.DisableNoDelay] for the paths that create the socket themselves.
+	disableNoDelay bool
+}
+
+func (d *Dialer) supportCache() *SupportCache {
+	return supportCacheOrDefault(d.SupportCache)
 }
 
-func (d *Dialer) dialAndWrite(ctx context.Context, network, address string, b []byte) (net.Conn, error) {
+func (d *Dialer) dialAndWrite(ctx context.Context, network, address string, bufs [][]byte) (net.Conn, error) {
 	c, err := d.Dialer.DialContext(ctx, network, address)
 	if err != nil {
 		return nil, err
 	}
-	if err = netConnWriteBytes(ctx, c, b); err != nil {
+	if err = netConnWriteBuffers(ctx, c, bufs); err != nil {
 		c.Close()
 		return nil, err
 	}
+	if tc, ok := c.(*net.TCPConn); ok {
+		setConnTFOInfo(tc, TFOInfo{Path: DialPathPlain})
+	}
 	return c, nil
 }
 
-func (d *Dialer) dialAndWriteTCPConn(ctx context.Context, network, address string, b []byte) (*net.TCPConn, error) {
+func (d *Dialer) dialAndWriteTCPConn(ctx context.Context, network, address string, bufs [][]byte) (*net.TCPConn, error) {
 	c, err := d.Dialer.DialContext(ctx, network, address)
 	if err != nil {
 		return nil, err
 	}
 	tc := c.(*net.TCPConn)
-	if err = netTCPConnWriteBytes(ctx, tc, b); err != nil {
+	if err = netTCPConnWriteBuffers(ctx, tc, bufs); err != nil {
 		tc.Close()
 		return nil, err
 	}
+	setConnTFOInfo(tc, TFOInfo{Path: DialPathPlain})
 	return tc, nil
 }
 
-func (d *Dialer) dialTCPAndWrite(ctx context.Context, network string, laddr, raddr netip.AddrPort, b []byte) (*net.TCPConn, error) {
+func (d *Dialer) dialTCPAndWrite(ctx context.Context, network string, laddr, raddr netip.AddrPort, bufs [][]byte) (*net.TCPConn, error) {
 	c, err := d.Dialer.DialTCP(ctx, network, laddr, raddr)
 	if err != nil {
 		return nil, err
 	}
-	if err = netTCPConnWriteBytes(ctx, c, b); err != nil {
+	if err = netTCPConnWriteBuffers(ctx, c, bufs); err != nil {
 		c.Close()
 		return nil, err
 	}
+	setConnTFOInfo(c, TFOInfo{Path: DialPathPlain})
 	return c, nil
 }
 
 // TFO returns true if the next dial call will attempt to enable TFO.
 func (d *Dialer) TFO() bool {
-	return !d.DisableTFO && (!d.Fallback || !comptimeDialNoTFO && runtimeDialTFOSupport.load() != dialTFOSupportNone)
+	return !d.DisableTFO && (!d.Fallback || !comptimeDialNoTFO && d.supportCache().loadDial() != dialTFOSupportNone)
 }
 
 // DialContext is like [net.Dialer.DialContext] but enables TFO whenever possible,
 // unless [Dialer.DisableTFO] is set to true.
 func (d *Dialer) DialContext(ctx context.Context, network, address string, b []byte) (net.Conn, error) {
+	if d.NetNS != nil {
+		return inNetNS(d.NetNS, func() (net.Conn, error) {
+			return d.netNSCopy().DialContext(ctx, network, address, b)
+		})
+	}
+	if !d.SocketOptions.isZero() && networkIsTCP(network) {
+		c, err := d.withSocketOptions().DialContext(ctx, network, address, b)
+		if err != nil {
+			return nil, err
+		}
+		d.SocketOptions.afterDial(c)
+		return c, nil
+	}
 	if len(b) == 0 {
 		return d.Dialer.DialContext(ctx, network, address)
 	}
+	return d.dialContext(ctx, network, address, [][]byte{b})
+}
+
+// DialBuffers is like [Dialer.DialContext] but takes the data in SYN as [net.Buffers].
+//
+// On platforms that support it, the buffers are passed to the kernel as a scatter/gather list,
+// without being copied into a single buffer. On Windows, ConnectEx only accepts a single buffer,
+// so only the first buffer is sent in SYN, and the rest is written after the handshake.
+//
+// bufs is not modified.
+func (d *Dialer) DialBuffers(ctx context.Context, network, address string, bufs net.Buffers) (net.Conn, error) {
+	if d.NetNS != nil {
+		return inNetNS(d.NetNS, func() (net.Conn, error) {
+			return d.netNSCopy().DialBuffers(ctx, network, address, bufs)
+		})
+	}
+	if !d.SocketOptions.isZero() && networkIsTCP(network) {
+		c, err := d.withSocketOptions().DialBuffers(ctx, network, address, bufs)
+		if err != nil {
+			return nil, err
+		}
+		d.SocketOptions.afterDial(c)
+		return c, nil
+	}
+	if buffersLen(bufs) == 0 {
+		return d.Dialer.DialContext(ctx, network, address)
+	}
+	return d.dialContext(ctx, network, address, bufs)
+}
+
+func (d *Dialer) dialContext(ctx context.Context, network, address string, bufs [][]byte) (net.Conn, error) {
 	if d.DisableTFO || !networkIsTCP(network) {
-		return d.dialAndWrite(ctx, network, address, b)
+		// Like DialAttempts, Stats.Dials only counts dial calls that attempted TFO.
+		ctx = withoutStats(ctx)
+		c, err := d.dialAndWrite(ctx, network, address, bufs)
+		if tc, ok := c.(*net.TCPConn); ok {
+			recordDialCall(ctx, time.Time{}, tc, err)
+		}
+		return c, err
 	}
-	tc, err := d.dialTFO(ctx, network, address, b) // tfo_bsd+windows.go, tfo_connect_stub.go, tfo_linux.go
+	start := time.Now()
+	tc, err := d.dialTFO(ctx, network, address, bufs) // tfo_bsd+windows.go, tfo_connect_stub.go, tfo_linux.go
+	recordDialCall(ctx, start, tc, err)
 	if err != nil {
 		return nil, err // return nil [net.Conn] instead of non-nil [net.Conn] with nil [*net.TCPConn] pointer
 	}
@@ -209,17 +343,44 @@ func (d *Dialer) Dial(network, address string, b []byte) (net.Conn, error) {
 
 // DialTCP is like [net.Dialer.DialTCP] but enables TFO whenever possible,
 // unless [Dialer.DisableTFO] is set to true.
+//
+// Like DialContext, DialTCP calls the Control or ControlContext function on every socket.
+// See [Dialer] for details.
 func (d *Dialer) DialTCP(ctx context.Context, network string, laddr, raddr netip.AddrPort, b []byte) (*net.TCPConn, error) {
+	if d.NetNS != nil {
+		return inNetNS(d.NetNS, func() (*net.TCPConn, error) {
+			return d.netNSCopy().DialTCP(ctx, network, laddr, raddr, b)
+		})
+	}
+	if !d.SocketOptions.isZero() && networkIsTCP(network) {
+		c, err := d.withSocketOptions().DialTCP(ctx, network, laddr, raddr, b)
+		if err != nil {
+			return nil, err
+		}
+		d.SocketOptions.afterDial(c)
+		return c, nil
+	}
 	if len(b) == 0 {
 		return d.Dialer.DialTCP(ctx, network, laddr, raddr)
 	}
 	if !networkIsTCP(network) {
 		return nil, &net.OpError{Op: "dial", Net: network, Source: opAddr(net.TCPAddrFromAddrPort(laddr)), Addr: opAddr(net.TCPAddrFromAddrPort(raddr)), Err: net.UnknownNetworkError(network)}
 	}
+	var (
+		bufs  = [][]byte{b}
+		start = time.Now()
+		c     *net.TCPConn
+		err   error
+	)
 	if d.DisableTFO {
-		return d.dialTCPAndWrite(ctx, network, laddr, raddr, b)
+		// Like DialAttempts, Stats.Dials only counts dial calls that attempted TFO.
+		ctx = withoutStats(ctx)
+		c, err = d.dialTCPAndWrite(ctx, network, laddr, raddr, bufs)
+	} else {
+		c, err = d.dialTCP(ctx, network, laddr, raddr, bufs) // tfo_bsd+windows.go, tfo_connect_stub.go, tfo_linux.go
 	}
-	return d.dialTCP(ctx, network, laddr, raddr, b) // tfo_bsd+windows.go, tfo_connect_stub.go, tfo_linux.go
+	recordDialCall(ctx, start, c, err)
+	return c, err
 }
 
 // Dial is like [net.Dial] but enables TFO whenever possible.
@@ -283,18 +444,50 @@ func connWriteFunc[C writeDeadliner](ctx context.Context, c C, fn func(C) error)
 	return fn(c)
 }
 
-// netConnWriteBytes is a convenience wrapper around [connWriteFunc] for writing bytes to a [net.Conn].
-func netConnWriteBytes(ctx context.Context, c net.Conn, b []byte) error {
+// netConnWriteBuffers is a convenience wrapper around [connWriteFunc] for writing buffers to a [net.Conn].
+func netConnWriteBuffers(ctx context.Context, c net.Conn, bufs [][]byte) error {
 	return connWriteFunc(ctx, c, func(c net.Conn) error {
-		_, err := c.Write(b)
-		return err
+		return writeBuffers(c, bufs)
 	})
 }
 
-// netTCPConnWriteBytes is a convenience wrapper around [connWriteFunc] for writing bytes to a [*net.TCPConn].
-func netTCPConnWriteBytes(ctx context.Context, c *net.TCPConn, b []byte) error {
+// netTCPConnWriteBuffers is a convenience wrapper around [connWriteFunc] for writing buffers to a [*net.TCPConn].
+func netTCPConnWriteBuffers(ctx context.Context, c *net.TCPConn, bufs [][]byte) error {
 	return connWriteFunc(ctx, c, func(c *net.TCPConn) error {
-		_, err := c.Write(b)
-		return err
+		return writeBuffers(c, bufs)
 	})
 }
+
+// writeBuffers writes bufs to w without modifying bufs.
+func writeBuffers(w io.Writer, bufs [][]byte) error {
+	if len(bufs) == 1 {
+		_, err := w.Write(bufs[0])
+		return err
+	}
+	v := net.Buffers(slices.Clone(bufs))
+	_, err := v.WriteTo(w)
+	return err
+}
+
+// buffersLen returns the total number of bytes in bufs.
+func buffersLen(bufs [][]byte) (n int) {
+	for _, b := range bufs {
+		n += len(b)
+	}
+	return n
+}
+
+// buffersAfter returns the buffers that remain after skipping the first n bytes of bufs.
+// bufs is not modified.
+func buffersAfter(bufs [][]byte, n int) [][]byte {
+	for len(bufs) > 0 && n >= len(bufs[0]) {
+		n -= len(bufs[0])
+		bufs = bufs[1:]
+	}
+	if n == 0 {
+		return bufs
+	}
+	rest := slices.Clone(bufs)
+	rest[0] = rest[0][n:]
+	return rest
+}
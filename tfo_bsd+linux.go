@@ -34,30 +34,60 @@ func ctrlNetwork(network string, family int) string {
 	return "tcp6"
 }
 
-func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *net.TCPAddr, b []byte, ctrlCtxFn func(context.Context, string, string, syscall.RawConn) error) (*net.TCPConn, error) {
+func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *net.TCPAddr, bufs [][]byte, ctrlCtxFn func(context.Context, string, string, syscall.RawConn) error) (*net.TCPConn, error) {
+	if proto, ok := d.multipathTCPProto(); ok { // tfo_linux.go, tfo_bsd.go
+		c, canRetry, err := d.dialSingleProto(ctx, network, laddr, raddr, bufs, ctrlCtxFn, proto)
+		// Like std, fall back to plain TCP on errors from the Multipath TCP attempt,
+		// but only if nothing has been sent, so that the payload is not sent twice.
+		if err == nil || !canRetry {
+			return c, err
+		}
+		reportFallback(ctx, FallbackReasonMultipathTCP, err)
+	}
+	c, _, err := d.dialSingleProto(ctx, network, laddr, raddr, bufs, ctrlCtxFn, unix.IPPROTO_TCP)
+	return c, err
+}
+
+// dialSingleProto is like dialSingle, but creates the socket with the given protocol.
+// Runtime fallback to dialing without TFO is only attempted for IPPROTO_TCP.
+//
+// For other protocols, protoUnsupported reports whether the attempt failed before anything
+// was sent, because the kernel does not support the protocol, or sending data in SYN with it.
+func (d *Dialer) dialSingleProto(ctx context.Context, network string, laddr, raddr *net.TCPAddr, bufs [][]byte, ctrlCtxFn func(context.Context, string, string, syscall.RawConn) error, proto int) (_ *net.TCPConn, protoUnsupported bool, err error) {
 	family, ipv6only := favoriteDialAddrFamily(network, laddr, raddr)
 
-	fd, err := d.socket(family)
+	fd, err := d.socket(family, proto)
 	if err != nil {
-		return nil, wrapSyscallError("socket", err)
+		return nil, true, wrapSyscallError("socket", err)
 	}
 
+	trace := ContextDialTrace(ctx)
+
 	if err = d.setIPv6Only(fd, family, ipv6only); err != nil {
+		trace.sockoptError("IPV6_V6ONLY", err)
 		unix.Close(fd)
-		return nil, os.NewSyscallError("setsockopt(IPV6_V6ONLY)", err)
+		return nil, true, os.NewSyscallError("setsockopt(IPV6_V6ONLY)", err)
 	}
 
-	if err = setNoDelay(fd, 1); err != nil {
+	if err = setNoDelay(fd, boolint(!d.disableNoDelay)); err != nil {
+		trace.sockoptError("TCP_NODELAY", err)
 		unix.Close(fd)
-		return nil, os.NewSyscallError("setsockopt(TCP_NODELAY)", err)
+		return nil, true, os.NewSyscallError("setsockopt(TCP_NODELAY)", err)
 	}
 
 	if err = setTFODialerFromSocket(uintptr(fd)); err != nil {
+		trace.sockoptError(setTFODialerFromSocketSockoptName, err)
 		if !d.Fallback || !errors.Is(err, errors.ErrUnsupported) {
 			unix.Close(fd)
-			return nil, os.NewSyscallError("setsockopt("+setTFODialerFromSocketSockoptName+")", err)
+			return nil, true, os.NewSyscallError("setsockopt("+setTFODialerFromSocketSockoptName+")", err)
 		}
-		runtimeDialTFOSupport.storeNone()
+		d.supportCache().storeDial(dialTFOSupportNone)
+		reportFallback(ctx, FallbackReasonUnsupported, err)
+	}
+
+	if err = setTFONoCookieIfEnabled(uintptr(fd), d.NoCookie, d.Fallback, trace); err != nil {
+		unix.Close(fd)
+		return nil, true, err
 	}
 
 	f := os.NewFile(uintptr(fd), "")
@@ -65,34 +95,34 @@ func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *n
 
 	rawConn, err := f.SyscallConn()
 	if err != nil {
-		return nil, err
+		return nil, true, err
 	}
 
 	if ctrlCtxFn != nil {
 		if err = ctrlCtxFn(ctx, ctrlNetwork(network, family), raddr.String(), rawConn); err != nil {
-			return nil, err
+			return nil, true, err
 		}
 	}
 
 	if laddr != nil {
 		lsa, err := unixSockaddrFromTCPAddr(laddr, family)
 		if err != nil {
-			return nil, err
+			return nil, true, err
 		}
 
 		if cErr := rawConn.Control(func(fd uintptr) {
 			err = unix.Bind(int(fd), lsa)
 		}); cErr != nil {
-			return nil, cErr
+			return nil, true, cErr
 		}
 		if err != nil {
-			return nil, wrapSyscallError("bind", err)
+			return nil, true, wrapSyscallError("bind", err)
 		}
 	}
 
 	rsa, err := unixSockaddrFromTCPAddr(raddr, family)
 	if err != nil {
-		return nil, err
+		return nil, true, err
 	}
 
 	var (
@@ -101,19 +131,25 @@ func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *n
 	)
 
 	if err = connWriteFunc(ctx, f, func(f *os.File) (err error) {
-		n, canFallback, err = connect(rawConn, rsa, b)
+		n, canFallback, err = connect(rawConn, rsa, bufs)
 		return err
 	}); err != nil {
+		if canFallback && proto != unix.IPPROTO_TCP {
+			// For example, Multipath TCP does not support MSG_FASTOPEN before Linux 6.2.
+			return nil, true, err
+		}
 		if d.Fallback && canFallback {
-			runtimeDialTFOSupport.storeNone()
-			return d.dialTCPAndWrite(ctx, network, laddr.AddrPort(), raddr.AddrPort(), b)
+			d.supportCache().storeDial(dialTFOSupportNone)
+			reportFallback(ctx, FallbackReasonUnsupported, err)
+			c, err := d.dialTCPAndWrite(ctx, network, laddr.AddrPort(), raddr.AddrPort(), bufs)
+			return c, false, err
 		}
-		return nil, err
+		return nil, false, err
 	}
 
 	c, err := net.FileConn(f)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	tc := c.(*net.TCPConn)
 
@@ -127,14 +163,20 @@ func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *n
 		_ = tc.SetKeepAlivePeriod(d.KeepAlive)
 	}
 
-	if n < len(b) {
-		if err = netTCPConnWriteBytes(ctx, tc, b[n:]); err != nil {
+	if rest := buffersAfter(bufs, n); len(rest) > 0 {
+		if err = netTCPConnWriteBuffers(ctx, tc, rest); err != nil {
 			tc.Close()
-			return nil, err
+			return nil, false, err
 		}
 	}
 
-	return tc, err
+	setConnTFOInfo(tc, TFOInfo{Path: socketDialPath, SYNBytes: n})
+	return tc, false, nil
+}
+
+// isResetOrTimedOut returns whether err is a connection reset or a connection timeout.
+func isResetOrTimedOut(err error) bool {
+	return errors.Is(err, unix.ECONNRESET) || errors.Is(err, unix.ETIMEDOUT)
 }
 
 func unixSockaddrFromTCPAddr(a *net.TCPAddr, family int) (unix.Sockaddr, error) {
@@ -172,7 +214,7 @@ func unixSockaddrFromTCPAddr(a *net.TCPAddr, family int) (unix.Sockaddr, error)
 	return nil, &net.AddrError{Err: "invalid address family", Addr: ip.String()}
 }
 
-func connect(rawConn syscall.RawConn, rsa unix.Sockaddr, b []byte) (n int, canFallback bool, err error) {
+func connect(rawConn syscall.RawConn, rsa unix.Sockaddr, bufs [][]byte) (n int, canFallback bool, err error) {
 	var done bool
 
 	if perr := rawConn.Write(func(fd uintptr) bool {
@@ -180,7 +222,7 @@ func connect(rawConn syscall.RawConn, rsa unix.Sockaddr, b []byte) (n int, canFa
 			return true
 		}
 
-		n, err = doConnect(fd, rsa, b)
+		n, err = doConnect(fd, rsa, bufs)
 		if err == unix.EINPROGRESS {
 			done = true
 			err = nil
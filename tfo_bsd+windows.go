@@ -8,16 +8,18 @@ import (
 	"net/netip"
 )
 
-func (d *Dialer) dialTFO(ctx context.Context, network, address string, b []byte) (*net.TCPConn, error) {
-	if d.Fallback && runtimeDialTFOSupport.load() == dialTFOSupportNone {
-		return d.dialAndWriteTCPConn(ctx, network, address, b)
+func (d *Dialer) dialTFO(ctx context.Context, network, address string, bufs [][]byte) (*net.TCPConn, error) {
+	if d.Fallback && d.supportCache().loadDial() == dialTFOSupportNone {
+		reportFallback(ctx, FallbackReasonCachedNoTFO, nil)
+		return d.dialAndWriteTCPConn(ctx, network, address, bufs)
 	}
-	return d.dialTFOFromSocket(ctx, network, address, b)
+	return d.dialTFOFromSocket(ctx, network, address, bufs)
 }
 
-func (d *Dialer) dialTCP(ctx context.Context, network string, laddr, raddr netip.AddrPort, b []byte) (*net.TCPConn, error) {
-	if d.Fallback && runtimeDialTFOSupport.load() == dialTFOSupportNone {
-		return d.dialTCPAndWrite(ctx, network, laddr, raddr, b)
+func (d *Dialer) dialTCP(ctx context.Context, network string, laddr, raddr netip.AddrPort, bufs [][]byte) (*net.TCPConn, error) {
+	if d.Fallback && d.supportCache().loadDial() == dialTFOSupportNone {
+		reportFallback(ctx, FallbackReasonCachedNoTFO, nil)
+		return d.dialTCPAndWrite(ctx, network, laddr, raddr, bufs)
 	}
-	return d.dialTCPAddrFromSocket(ctx, network, laddr, raddr, b)
+	return d.dialTCPAddrFromSocket(ctx, network, laddr, raddr, bufs)
 }
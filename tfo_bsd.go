@@ -10,3 +10,9 @@ func setTFODialerFromSocket(fd uintptr) error {
 func doConnectCanFallback(_ error) bool {
 	return false
 }
+
+// multipathTCPProto always returns false. On macOS, [Dialer.socket] handles
+// Multipath TCP by using AF_MULTIPATH. FreeBSD does not support Multipath TCP.
+func (*Dialer) multipathTCPProto() (int, bool) {
+	return 0, false
+}
@@ -8,7 +8,6 @@ import (
 	"net/netip"
 	"os"
 	"syscall"
-	"time"
 )
 
 const comptimeDialNoTFO = false
@@ -49,321 +48,166 @@ func tcpAddrIs4(a *net.TCPAddr) bool {
 	return a != nil && a.IP.To4() != nil
 }
 
-func (d *Dialer) dialCtx(ctx context.Context) (context.Context, context.CancelFunc) {
-	if ctx == nil {
-		panic("nil context")
-	}
-	deadline := d.deadline(ctx, time.Now())
-	var cancel1, cancel2 context.CancelFunc
-	if !deadline.IsZero() {
-		if d, ok := ctx.Deadline(); !ok || deadline.Before(d) {
-			var subCtx context.Context
-			subCtx, cancel1 = context.WithDeadline(ctx, deadline)
-			ctx = subCtx
-		}
-	}
-	if oldCancel := d.Cancel; oldCancel != nil {
-		var subCtx context.Context
-		subCtx, cancel2 = context.WithCancel(ctx)
-		go func() {
-			select {
-			case <-oldCancel:
-				cancel2()
-			case <-subCtx.Done():
-			}
-		}()
-		ctx = subCtx
-	}
-	return ctx, func() {
-		if cancel1 != nil {
-			cancel1()
-		}
-		if cancel2 != nil {
-			cancel2()
-		}
-	}
-}
-
-func (d *Dialer) dialTCPAddrFromSocket(ctx context.Context, network string, laddr, raddr netip.AddrPort, b []byte) (*net.TCPConn, error) {
+func (d *Dialer) dialTCPAddrFromSocket(ctx context.Context, network string, laddr, raddr netip.AddrPort, bufs [][]byte) (*net.TCPConn, error) {
 	ctx, cancel := d.dialCtx(ctx)
 	defer cancel()
 
 	la := net.TCPAddrFromAddrPort(laddr)
 	ra := net.TCPAddrFromAddrPort(raddr)
 
-	c, err := d.dialSingle(ctx, network, la, ra, b, nil)
+	c, err := d.dialSingleBlackhole(ctx, network, la, ra, bufs, d.controlContext())
 	if err != nil {
 		return nil, &net.OpError{Op: "dial", Net: network, Source: la, Addr: ra, Err: err}
 	}
 	return c, nil
 }
 
-func (d *Dialer) dialTFOFromSocket(ctx context.Context, network, address string, b []byte) (*net.TCPConn, error) {
+func (d *Dialer) dialTFOFromSocket(ctx context.Context, network, address string, bufs [][]byte) (*net.TCPConn, error) {
 	ctx, cancel := d.dialCtx(ctx)
 	defer cancel()
 
-	var laddr *net.TCPAddr
-	if d.LocalAddr != nil {
-		la, ok := d.LocalAddr.(*net.TCPAddr)
-		if !ok {
-			return nil, &net.OpError{
-				Op:     "dial",
-				Net:    network,
-				Source: nil,
-				Addr:   nil,
-				Err: &net.AddrError{
-					Err:  "mismatched local address type",
-					Addr: d.LocalAddr.String(),
-				},
-			}
-		}
-		laddr = la
-	}
-
-	host, port, err := net.SplitHostPort(address)
+	laddrPort, raddrs, err := d.resolveAddrPorts(ctx, network, address)
 	if err != nil {
-		return nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: err}
+		return nil, err
 	}
-	portNum, err := d.Resolver.LookupPort(ctx, network, port)
-	if err != nil {
-		return nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: err}
-	}
-	ipaddrs, err := d.Resolver.LookupIPAddr(ctx, host)
-	if err != nil {
-		return nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: err}
+	return d.dialAddrPortsFromSocket(ctx, network, laddrPort, raddrs, bufs)
+}
+
+// dialAddrPortsFromSocket is like dialTFOFromSocket, but connects to the already resolved raddrs.
+func (d *Dialer) dialAddrPortsFromSocket(ctx context.Context, network string, laddrPort netip.AddrPort, raddrs []netip.AddrPort, bufs [][]byte) (*net.TCPConn, error) {
+	var laddr *net.TCPAddr
+	if laddrPort.IsValid() {
+		laddr = net.TCPAddrFromAddrPort(laddrPort)
 	}
 
-	var addrs []*net.TCPAddr
+	ctrlCtxFn := d.controlContext()
 
-	for _, ipaddr := range ipaddrs {
-		if laddr != nil && !laddr.IP.IsUnspecified() && !matchAddrFamily(laddr.IP, ipaddr.IP) {
-			continue
+	c, err := dialHappyEyeballs(ctx, raddrs, d.payloadAttemptDelay(bufs), func(ctx context.Context, raddr netip.AddrPort) (*net.TCPConn, error) {
+		ra := net.TCPAddrFromAddrPort(raddr)
+		c, err := d.dialSingleBlackhole(ctx, network, laddr, ra, bufs, ctrlCtxFn)
+		if err != nil {
+			return nil, d.wrapDialError(network, ra, err)
 		}
-		addrs = append(addrs, &net.TCPAddr{
-			IP:   ipaddr.IP,
-			Port: portNum,
-			Zone: ipaddr.Zone,
-		})
-	}
-
-	var primaries, fallbacks []*net.TCPAddr
-	if d.FallbackDelay >= 0 && network == "tcp" {
-		primaries, fallbacks = partition(addrs, func(a *net.TCPAddr) bool {
-			return a.IP.To4() != nil
-		})
-	} else {
-		primaries = addrs
+		return c, nil
+	})
+	if err != nil {
+		return nil, d.wrapDialError(network, nil, err)
 	}
-
-	return d.dialParallel(ctx, network, laddr, primaries, fallbacks, b)
+	return c, nil
 }
 
-// dialParallel races two copies of dialSerial, giving the first a
-// head start. It returns the first established connection and
-// closes the others. Otherwise it returns an error from the first
-// primary address.
-func (d *Dialer) dialParallel(ctx context.Context, network string, laddr *net.TCPAddr, primaries, fallbacks []*net.TCPAddr, b []byte) (*net.TCPConn, error) {
-	if len(fallbacks) == 0 {
-		return d.dialSerial(ctx, network, laddr, primaries, b)
+// resolveAddrPorts returns d.LocalAddr, and the addresses address resolves to,
+// sorted for dialing with [dialHappyEyeballs]. The lookup is reported to the [DialTrace] of ctx.
+// Like [net/http/httptrace], IP literals are not looked up, and not reported.
+func (d *Dialer) resolveAddrPorts(ctx context.Context, network, address string) (laddr netip.AddrPort, raddrs []netip.AddrPort, err error) {
+	laddr, err = d.localAddrPort(network)
+	if err != nil {
+		return netip.AddrPort{}, nil, err
 	}
 
-	returned := make(chan struct{})
-	defer close(returned)
-
-	type dialResult struct {
-		*net.TCPConn
-		error
-		primary bool
-		done    bool
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return netip.AddrPort{}, nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: err}
 	}
-	results := make(chan dialResult) // unbuffered
-
-	startRacer := func(ctx context.Context, primary bool) {
-		ras := primaries
-		if !primary {
-			ras = fallbacks
+	portNum, err := d.Resolver.LookupPort(ctx, network, port)
+	if err != nil {
+		return netip.AddrPort{}, nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: err}
+	}
+	var ips []netip.Addr
+	switch {
+	case host == "":
+		// Like std, an empty host means the local system.
+		if network == "tcp4" || laddr.Addr().Is4() {
+			ips = []netip.Addr{netip.IPv4Unspecified()}
+		} else {
+			ips = []netip.Addr{netip.IPv6Unspecified()}
 		}
-		c, err := d.dialSerial(ctx, network, laddr, ras, b)
-		select {
-		case results <- dialResult{TCPConn: c, error: err, primary: primary, done: true}:
-		case <-returned:
-			if c != nil {
-				c.Close()
-			}
+	default:
+		if ip, perr := netip.ParseAddr(host); perr == nil {
+			ips = []netip.Addr{ip}
+			break
+		}
+		trace := ContextDialTrace(ctx)
+		trace.dnsStart(host)
+		ips, err = d.Resolver.LookupNetIP(ctx, lookupIPNetwork(network), host)
+		trace.dnsDone(ips, err)
+		if err != nil {
+			return netip.AddrPort{}, nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: err}
 		}
 	}
 
-	var primary, fallback dialResult
-
-	// Start the main racer.
-	primaryCtx, primaryCancel := context.WithCancel(ctx)
-	defer primaryCancel()
-	go startRacer(primaryCtx, true)
-
-	// Start the timer for the fallback racer.
-	fallbackDelay := d.FallbackDelay
-	if fallbackDelay == 0 {
-		const defaultFallbackDelay = 300 * time.Millisecond
-		fallbackDelay = defaultFallbackDelay
+	raddrs = make([]netip.AddrPort, len(ips))
+	for i, ip := range ips {
+		raddrs[i] = netip.AddrPortFrom(ip, uint16(portNum))
 	}
-	fallbackTimer := time.NewTimer(fallbackDelay)
-	defer fallbackTimer.Stop()
-
-	for {
-		select {
-		case <-fallbackTimer.C:
-			fallbackCtx, fallbackCancel := context.WithCancel(ctx)
-			defer fallbackCancel()
-			go startRacer(fallbackCtx, false)
-
-		case res := <-results:
-			if res.error == nil {
-				return res.TCPConn, nil
-			}
-			if res.primary {
-				primary = res
-			} else {
-				fallback = res
-			}
-			if primary.done && fallback.done {
-				return nil, primary.error
-			}
-			if res.primary && fallbackTimer.Stop() {
-				// If we were able to stop the timer, that means it
-				// was running (hadn't yet started the fallback), but
-				// we just got an error on the primary path, so start
-				// the fallback immediately (in 0 nanoseconds).
-				fallbackTimer.Reset(0)
-			}
-		}
+	raddrs = sortAddrPorts(network, laddr.Addr(), raddrs)
+	if len(raddrs) == 0 {
+		return netip.AddrPort{}, nil, &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: errMissingAddress}
 	}
+	return laddr, raddrs, nil
 }
 
-// dialSerial connects to a list of addresses in sequence, returning
-// either the first successful connection, or the first error.
-func (d *Dialer) dialSerial(ctx context.Context, network string, laddr *net.TCPAddr, ras []*net.TCPAddr, b []byte) (*net.TCPConn, error) {
-	var firstErr error // The error from the first address is most relevant.
+// lookupIPNetwork returns the IP network to resolve for the TCP network.
+func lookupIPNetwork(network string) string {
+	switch network {
+	case "tcp4":
+		return "ip4"
+	case "tcp6":
+		return "ip6"
+	default:
+		return "ip"
+	}
+}
 
-	for i, ra := range ras {
-		select {
-		case <-ctx.Done():
-			return nil, &net.OpError{Op: "dial", Net: network, Source: d.LocalAddr, Addr: ra, Err: ctx.Err()}
-		default:
-		}
+// dialSingleBlackhole is like dialSingle, but consults and updates [Dialer.BlackholeCache].
+func (d *Dialer) dialSingleBlackhole(ctx context.Context, network string, laddr, raddr *net.TCPAddr, bufs [][]byte, ctrlCtxFn func(context.Context, string, string, syscall.RawConn) error) (*net.TCPConn, error) {
+	cache := d.BlackholeCache
+	if cache == nil {
+		return d.dialSingle(ctx, network, laddr, raddr, bufs, ctrlCtxFn)
+	}
 
-		dialCtx := ctx
-		if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
-			partialDeadline, err := partialDeadline(time.Now(), deadline, len(ras)-i)
-			if err != nil {
-				// Ran out of time.
-				if firstErr == nil {
-					firstErr = &net.OpError{Op: "dial", Net: network, Source: d.LocalAddr, Addr: ra, Err: err}
-				}
-				break
-			}
-			if partialDeadline.Before(deadline) {
-				var cancel context.CancelFunc
-				dialCtx, cancel = context.WithDeadline(ctx, partialDeadline)
-				defer cancel()
-			}
-		}
+	addr := raddr.AddrPort().Addr()
+	if cache.Blocked(addr) {
+		reportFallback(ctx, FallbackReasonBlackholed, nil)
+		return d.dialTCPAndWrite(ctx, network, laddr.AddrPort(), raddr.AddrPort(), bufs)
+	}
 
-		ctrlCtxFn := d.ControlContext
-		if ctrlCtxFn == nil && d.Control != nil {
-			ctrlCtxFn = func(ctx context.Context, network, address string, c syscall.RawConn) error {
-				return d.Control(network, address, c)
-			}
-		}
+	attemptCtx, cancel := context.WithTimeout(ctx, cache.attemptTimeout())
+	defer cancel()
 
-		c, err := d.dialSingle(dialCtx, network, laddr, ra, b, ctrlCtxFn)
-		if err == nil {
+	c, err := d.dialSingle(attemptCtx, network, laddr, raddr, bufs, ctrlCtxFn)
+	if err == nil {
+		if info, _ := ConnTFOInfo(c); info.Path == DialPathPlain {
 			return c, nil
 		}
-		if firstErr == nil {
-			var ok bool
-			firstErr, ok = err.(*net.OpError)
-			if !ok {
-				firstErr = &net.OpError{Op: "dial", Net: network, Source: d.LocalAddr, Addr: ra, Err: err}
+		if err = waitHandshake(attemptCtx, c); err == nil { // blackhole_linux.go, blackhole_stub.go
+			if synDataLost(c) {
+				cache.ReportFailure(addr)
+			} else {
+				cache.ReportSuccess(addr)
 			}
+			return c, nil
 		}
+		c.Close()
 	}
 
-	if firstErr == nil {
-		firstErr = &net.OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: errMissingAddress}
-	}
-	return nil, firstErr
-}
-
-func matchAddrFamily(x, y net.IP) bool {
-	return x.To4() != nil && y.To4() != nil || x.To16() != nil && x.To4() == nil && y.To16() != nil && y.To4() == nil
-}
-
-// partition divides an address list into two categories, using a
-// strategy function to assign a boolean label to each address.
-// The first address, and any with a matching label, are returned as
-// primaries, while addresses with the opposite label are returned
-// as fallbacks. For non-empty inputs, primaries is guaranteed to be
-// non-empty.
-func partition(addrs []*net.TCPAddr, strategy func(*net.TCPAddr) bool) (primaries, fallbacks []*net.TCPAddr) {
-	var primaryLabel bool
-	for i, addr := range addrs {
-		label := strategy(addr)
-		if i == 0 || label == primaryLabel {
-			primaryLabel = label
-			primaries = append(primaries, addr)
-		} else {
-			fallbacks = append(fallbacks, addr)
-		}
-	}
-	return
-}
-
-func minNonzeroTime(a, b time.Time) time.Time {
-	if a.IsZero() {
-		return b
-	}
-	if b.IsZero() || a.Before(b) {
-		return a
+	// Do not retry if the caller gave up.
+	if ctx.Err() != nil {
+		return nil, err
 	}
-	return b
-}
 
-// deadline returns the earliest of:
-//   - now+Timeout
-//   - d.Deadline
-//   - the context's deadline
-//
-// Or zero, if none of Timeout, Deadline, or context's deadline is set.
-func (d *Dialer) deadline(ctx context.Context, now time.Time) (earliest time.Time) {
-	if d.Timeout != 0 { // including negative, for historical reasons
-		earliest = now.Add(d.Timeout)
+	// Only timeouts and resets suggest that TFO is blackholed on the path.
+	// Other errors, such as EHOSTUNREACH, are not specific to TFO, and are not retried.
+	if attemptCtx.Err() == nil && !isResetOrTimedOut(err) { // tfo_bsd+linux.go, tfo_windows_checklinkname0.go
+		return nil, err
 	}
-	if d, ok := ctx.Deadline(); ok {
-		earliest = minNonzeroTime(earliest, d)
-	}
-	return minNonzeroTime(earliest, d.Deadline)
-}
 
-// partialDeadline returns the deadline to use for a single address,
-// when multiple addresses are pending.
-func partialDeadline(now, deadline time.Time, addrsRemaining int) (time.Time, error) {
-	if deadline.IsZero() {
-		return deadline, nil
+	reportFallback(ctx, FallbackReasonBlackholeRetry, err)
+	c, perr := d.dialTCPAndWrite(ctx, network, laddr.AddrPort(), raddr.AddrPort(), bufs)
+	if perr != nil {
+		// The destination is unreachable regardless of TFO.
+		return nil, err
 	}
-	timeRemaining := deadline.Sub(now)
-	if timeRemaining <= 0 {
-		return time.Time{}, os.ErrDeadlineExceeded
-	}
-	// Tentatively allocate equal time to each remaining address.
-	timeout := timeRemaining / time.Duration(addrsRemaining)
-	// If the time per address is too short, steal from the end of the list.
-	const saneMinimum = 2 * time.Second
-	if timeout < saneMinimum {
-		if timeRemaining < saneMinimum {
-			timeout = timeRemaining
-		} else {
-			timeout = saneMinimum
-		}
-	}
-	return now.Add(timeout), nil
+	cache.ReportFailure(addr)
+	return c, nil
 }
@@ -10,16 +10,16 @@ import (
 
 const comptimeDialNoTFO = true
 
-func (d *Dialer) dialTFO(ctx context.Context, network, address string, b []byte) (*net.TCPConn, error) {
+func (d *Dialer) dialTFO(ctx context.Context, network, address string, bufs [][]byte) (*net.TCPConn, error) {
 	if d.Fallback {
-		return d.dialAndWriteTCPConn(ctx, network, address, b)
+		return d.dialAndWriteTCPConn(ctx, network, address, bufs)
 	}
 	return nil, ErrPlatformUnsupported
 }
 
-func (d *Dialer) dialTCP(ctx context.Context, network string, laddr, raddr netip.AddrPort, b []byte) (*net.TCPConn, error) {
+func (d *Dialer) dialTCP(ctx context.Context, network string, laddr, raddr netip.AddrPort, bufs [][]byte) (*net.TCPConn, error) {
 	if d.Fallback {
-		return d.dialTCPAndWrite(ctx, network, laddr, raddr, b)
+		return d.dialTCPAndWrite(ctx, network, laddr, raddr, bufs)
 	}
 	return nil, ErrPlatformUnsupported
 }
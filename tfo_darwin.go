@@ -20,6 +20,9 @@ func (lc *ListenConfig) listenTFO(ctx context.Context, network, address string)
 	// Copy these values to avoid referencing lc in llc.Control.
 	ctrlFn := lc.Control
 	fallback := lc.Fallback
+	keys := lc.TFOKeys
+	noCookie := lc.NoCookie
+	supportCache := supportCacheOrDefault(lc.SupportCache)
 	llc := *lc
 	llc.Control = func(network, address string, c syscall.RawConn) (err error) {
 		if ctrlFn != nil {
@@ -38,9 +41,27 @@ func (lc *ListenConfig) listenTFO(ctx context.Context, network, address string)
 			if !fallback || !errors.Is(err, errors.ErrUnsupported) {
 				return os.NewSyscallError("setsockopt(TCP_FASTOPEN_FORCE_ENABLE)", err)
 			}
-			runtimeListenNoTFO.Store(true)
+			supportCache.storeListenNoTFO()
+			return nil
 		}
-		return nil
+
+		if len(keys) > 0 {
+			if cerr := c.Control(func(fd uintptr) {
+				err = setTFOKeys(fd, keys)
+			}); cerr != nil {
+				return cerr
+			}
+			if err != nil {
+				return os.NewSyscallError("setsockopt(TCP_FASTOPEN_KEY)", err)
+			}
+		}
+
+		if cerr := c.Control(func(fd uintptr) {
+			err = setTFONoCookieIfEnabled(fd, noCookie, fallback, nil)
+		}); cerr != nil {
+			return cerr
+		}
+		return err
 	}
 
 	ln, err := llc.ListenConfig.Listen(ctx, network, address)
@@ -66,7 +87,7 @@ func (lc *ListenConfig) listenTFO(ctx context.Context, network, address string)
 		if !fallback || !errors.Is(err, errors.ErrUnsupported) {
 			return nil, os.NewSyscallError("setsockopt(TCP_FASTOPEN)", err)
 		}
-		runtimeListenNoTFO.Store(true)
+		supportCache.storeListenNoTFO()
 	}
 
 	return ln, nil
@@ -74,13 +95,13 @@ func (lc *ListenConfig) listenTFO(ctx context.Context, network, address string)
 
 const AF_MULTIPATH = 39
 
-func (d *Dialer) socket(domain int) (fd int, err error) {
+func (d *Dialer) socket(domain, proto int) (fd int, err error) {
 	if d.MultipathTCP() {
 		domain = AF_MULTIPATH
 	}
 
 	syscall.ForkLock.RLock()
-	fd, err = unix.Socket(domain, unix.SOCK_STREAM, unix.IPPROTO_TCP)
+	fd, err = unix.Socket(domain, unix.SOCK_STREAM, proto)
 	if err != nil {
 		syscall.ForkLock.RUnlock()
 		return 0, os.NewSyscallError("socket", err)
@@ -107,18 +128,24 @@ const setTFODialerFromSocketSockoptName = "TCP_FASTOPEN_FORCE_ENABLE"
 
 const connectSyscallName = "connectx"
 
-func doConnect(fd uintptr, rsa unix.Sockaddr, b []byte) (int, error) {
+const socketDialPath = DialPathConnectx
+
+func doConnect(fd uintptr, rsa unix.Sockaddr, bufs [][]byte) (int, error) {
 	var (
 		flags uint32
 		iov   []unix.Iovec
 	)
-	if len(b) > 0 {
+	if buffersLen(bufs) > 0 {
 		flags = unix.CONNECT_DATA_IDEMPOTENT
-		iov = []unix.Iovec{
-			{
+		iov = make([]unix.Iovec, 0, len(bufs))
+		for _, b := range bufs {
+			if len(b) == 0 {
+				continue
+			}
+			iov = append(iov, unix.Iovec{
 				Base: &b[0],
 				Len:  uint64(len(b)),
-			},
+			})
 		}
 	}
 	n, err := unix.Connectx(int(fd), 0, nil, rsa, unix.SAE_ASSOCID_ANY, flags, iov, nil)
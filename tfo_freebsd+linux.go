@@ -6,8 +6,8 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func (*Dialer) socket(domain int) (int, error) {
-	return unix.Socket(domain, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, unix.IPPROTO_TCP)
+func (*Dialer) socket(domain, proto int) (int, error) {
+	return unix.Socket(domain, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, proto)
 }
 
 func (*Dialer) setIPv6Only(fd int, family int, ipv6only bool) error {
@@ -16,6 +16,8 @@ func (*Dialer) setIPv6Only(fd int, family int, ipv6only bool) error {
 
 const connectSyscallName = "sendmsg"
 
-func doConnect(fd uintptr, rsa unix.Sockaddr, b []byte) (int, error) {
-	return unix.SendmsgN(int(fd), b, nil, rsa, sendtoImplicitConnectFlag|unix.MSG_NOSIGNAL)
+const socketDialPath = DialPathSendmsg
+
+func doConnect(fd uintptr, rsa unix.Sockaddr, bufs [][]byte) (int, error) {
+	return unix.SendmsgBuffers(int(fd), bufs, nil, rsa, sendtoImplicitConnectFlag|unix.MSG_NOSIGNAL)
 }
@@ -6,6 +6,8 @@ import (
 	"net"
 	"net/netip"
 	"os"
+	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"golang.org/x/sys/unix"
@@ -19,6 +21,29 @@ func setTFODialerFromSocket(_ uintptr) error {
 
 const sendtoImplicitConnectFlag = unix.MSG_FASTOPEN
 
+// mptcpAvailable reports whether the kernel supports Multipath TCP.
+//
+// Modified from go1.26 src/net/mptcpsock_linux.go
+var mptcpAvailable = sync.OnceValue(func() bool {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, unix.IPPROTO_MPTCP)
+	switch err {
+	case unix.EPROTONOSUPPORT, unix.EINVAL: // Not supported: >= v5.6, < v5.6
+		return false
+	case nil:
+		unix.Close(fd)
+	}
+	// Any other error means MPTCP was not available, but it might be later.
+	return true
+})
+
+// multipathTCPProto returns IPPROTO_MPTCP if d requests Multipath TCP and the kernel supports it.
+func (d *Dialer) multipathTCPProto() (int, bool) {
+	if d.MultipathTCP() && mptcpAvailable() {
+		return unix.IPPROTO_MPTCP, true
+	}
+	return 0, false
+}
+
 // doConnectCanFallback returns whether err from [doConnect] indicates lack of TFO support.
 func doConnectCanFallback(err error) bool {
 	// On Linux, calling sendto() on an unconnected TCP socket with zero or invalid flags
@@ -28,81 +53,95 @@ func doConnectCanFallback(err error) bool {
 	return err == unix.EPIPE || err == unix.EOPNOTSUPP
 }
 
-func (a *atomicDialTFOSupport) casLinuxSendto() bool {
-	return a.v.CompareAndSwap(uint32(dialTFOSupportDefault), uint32(dialTFOSupportLinuxSendto))
-}
-
-func (d *Dialer) dialTFO(ctx context.Context, network, address string, b []byte) (*net.TCPConn, error) {
+func (d *Dialer) dialTFO(ctx context.Context, network, address string, bufs [][]byte) (*net.TCPConn, error) {
 	fallback := d.Fallback
 	if fallback {
-		switch runtimeDialTFOSupport.load() {
+		switch d.supportCache().loadDial() {
 		case dialTFOSupportNone:
-			return d.dialAndWriteTCPConn(ctx, network, address, b)
+			reportFallback(ctx, FallbackReasonCachedNoTFO, nil)
+			return d.dialAndWriteTCPConn(ctx, network, address, bufs)
 		case dialTFOSupportLinuxSendto:
-			return d.dialTFOFromSocket(ctx, network, address, b)
+			reportFallback(ctx, FallbackReasonCachedSendto, nil)
+			return d.dialTFOFromSocket(ctx, network, address, bufs)
 		}
 	}
+	if d.BlackholeCache != nil {
+		return d.dialTFOFromSocket(ctx, network, address, bufs)
+	}
 
-	var canFallback bool
-	ctrlCtxFn := d.ControlContext
-	ctrlFn := d.Control
-	ld := *d
-	// Avoid referencing d in ld.ControlContext to prevent it from being captured by the closure.
-	ld.ControlContext = func(ctx context.Context, network, address string, c syscall.RawConn) (err error) {
-		switch {
-		case ctrlCtxFn != nil:
-			if err = ctrlCtxFn(ctx, network, address, c); err != nil {
-				return err
-			}
-		case ctrlFn != nil:
-			if err = ctrlFn(network, address, c); err != nil {
-				return err
-			}
-		}
-
-		if cerr := c.Control(func(fd uintptr) {
-			err = setTFODialer(fd)
-		}); cerr != nil {
-			return cerr
-		}
+	ctx, cancel := d.dialCtx(ctx)
+	defer cancel()
 
-		if err != nil {
-			if fallback && errors.Is(err, errors.ErrUnsupported) {
-				canFallback = true
-			}
-			return os.NewSyscallError("setsockopt(TCP_FASTOPEN_CONNECT)", err)
-		}
-		return nil
+	// Resolve names and race addresses in this package, like the sendto(MSG_FASTOPEN) path,
+	// so that both are reported to the [DialTrace] of ctx, and the fallback reuses the addresses.
+	laddr, raddrs, err := d.resolveAddrPorts(ctx, network, address)
+	if err != nil {
+		return nil, err
 	}
 
-	nc, err := ld.Dialer.DialContext(ctx, network, address)
+	var canFallback atomic.Bool
+	ld := d.connectOptionDialer(&canFallback)
+
+	// The payload is only written after the race is won, so all attempts can be raced.
+	c, err := dialHappyEyeballs(ctx, raddrs, d.connectionAttemptDelay(), func(ctx context.Context, raddr netip.AddrPort) (*net.TCPConn, error) {
+		return ld.Dialer.DialTCP(ctx, network, laddr, raddr)
+	})
 	if err != nil {
-		if fallback && canFallback {
-			runtimeDialTFOSupport.casLinuxSendto()
-			return d.dialTFOFromSocket(ctx, network, address, b)
+		if fallback && canFallback.Load() {
+			d.supportCache().casDial(dialTFOSupportDefault, dialTFOSupportLinuxSendto)
+			reportFallback(ctx, FallbackReasonSendto, err)
+			return d.dialAddrPortsFromSocket(ctx, network, laddr, raddrs, bufs)
 		}
-		return nil, err
+		return nil, d.wrapDialError(network, nil, err)
 	}
-	tc := nc.(*net.TCPConn)
-	if err = netTCPConnWriteBytes(ctx, tc, b); err != nil {
-		tc.Close()
+	if err = writeConnectOption(ctx, c, bufs); err != nil {
+		c.Close()
 		return nil, err
 	}
-	return tc, nil
+	return c, nil
 }
 
-func (d *Dialer) dialTCP(ctx context.Context, network string, laddr, raddr netip.AddrPort, b []byte) (*net.TCPConn, error) {
+func (d *Dialer) dialTCP(ctx context.Context, network string, laddr, raddr netip.AddrPort, bufs [][]byte) (*net.TCPConn, error) {
 	fallback := d.Fallback
 	if fallback {
-		switch runtimeDialTFOSupport.load() {
+		switch d.supportCache().loadDial() {
 		case dialTFOSupportNone:
-			return d.dialTCPAndWrite(ctx, network, laddr, raddr, b)
+			reportFallback(ctx, FallbackReasonCachedNoTFO, nil)
+			return d.dialTCPAndWrite(ctx, network, laddr, raddr, bufs)
 		case dialTFOSupportLinuxSendto:
-			return d.dialTCPAddrFromSocket(ctx, network, laddr, raddr, b)
+			reportFallback(ctx, FallbackReasonCachedSendto, nil)
+			return d.dialTCPAddrFromSocket(ctx, network, laddr, raddr, bufs)
+		}
+	}
+	if d.BlackholeCache != nil {
+		return d.dialTCPAddrFromSocket(ctx, network, laddr, raddr, bufs)
+	}
+
+	var canFallback atomic.Bool
+	ld := d.connectOptionDialer(&canFallback)
+
+	c, err := ld.Dialer.DialTCP(ctx, network, laddr, raddr)
+	if err != nil {
+		if fallback && canFallback.Load() {
+			d.supportCache().casDial(dialTFOSupportDefault, dialTFOSupportLinuxSendto)
+			reportFallback(ctx, FallbackReasonSendto, err)
+			return d.dialTCPAddrFromSocket(ctx, network, laddr, raddr, bufs)
 		}
+		return nil, err
 	}
+	if err = writeConnectOption(ctx, c, bufs); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
 
-	var canFallback bool
+// connectOptionDialer returns a copy of d, whose ControlContext function also sets
+// TCP_FASTOPEN_CONNECT. If setting it fails due to lack of support, and d.Fallback is set,
+// canFallback is set to true.
+func (d *Dialer) connectOptionDialer(canFallback *atomic.Bool) *Dialer {
+	fallback := d.Fallback
+	noCookie := d.NoCookie
 	ctrlCtxFn := d.ControlContext
 	ctrlFn := d.Control
 	ld := *d
@@ -125,26 +164,96 @@ func (d *Dialer) dialTCP(ctx context.Context, network string, laddr, raddr netip
 			return cerr
 		}
 
+		trace := ContextDialTrace(ctx)
+
 		if err != nil {
+			trace.sockoptError("TCP_FASTOPEN_CONNECT", err)
 			if fallback && errors.Is(err, errors.ErrUnsupported) {
-				canFallback = true
+				canFallback.Store(true)
 			}
 			return os.NewSyscallError("setsockopt(TCP_FASTOPEN_CONNECT)", err)
 		}
-		return nil
+
+		if cerr := c.Control(func(fd uintptr) {
+			err = setTFONoCookieIfEnabled(fd, noCookie, fallback, trace)
+		}); cerr != nil {
+			return cerr
+		}
+		return err
 	}
+	return &ld
+}
 
-	c, err := ld.Dialer.DialTCP(ctx, network, laddr, raddr)
+// writeConnectOption writes bufs to c, which was dialed with TCP_FASTOPEN_CONNECT,
+// and records the number of bytes carried in the SYN.
+//
+// The first writev(2) on such a socket initiates the handshake, and returns the
+// number of bytes sent in the SYN. Writing through [syscall.RawConn] allows us
+// to observe this number, which would otherwise be hidden by [net.TCPConn.Write].
+func writeConnectOption(ctx context.Context, c *net.TCPConn, bufs [][]byte) error {
+	rawConn, err := c.SyscallConn()
 	if err != nil {
-		if fallback && canFallback {
-			runtimeDialTFOSupport.casLinuxSendto()
-			return d.dialTCPAddrFromSocket(ctx, network, laddr, raddr, b)
+		return err
+	}
+
+	// Without a cached cookie, connect(2) does not defer the handshake,
+	// and sends a SYN with a cookie request instead.
+	var state uint8
+	if cerr := rawConn.Control(func(fd uintptr) {
+		var info *unix.TCPInfo
+		if info, err = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO); err == nil {
+			state = info.State
 		}
-		return nil, err
+	}); cerr != nil {
+		return cerr
 	}
-	if err = netTCPConnWriteBytes(ctx, c, b); err != nil {
-		c.Close()
-		return nil, err
+	if err != nil {
+		return os.NewSyscallError("getsockopt(TCP_INFO)", err)
 	}
-	return c, nil
+
+	var (
+		n, synBytes int
+		first       = state == unix.BPF_TCP_SYN_SENT
+	)
+
+	if err = connWriteFunc(ctx, c, func(c *net.TCPConn) (err error) {
+		if perr := rawConn.Write(func(fd uintptr) bool {
+			for {
+				n, err = unix.Writev(int(fd), bufs)
+				if err != unix.EINTR {
+					break
+				}
+			}
+			switch err {
+			case unix.EINPROGRESS:
+				// The SYN was sent without data. Wait for the handshake to complete,
+				// and write the data after it.
+				first = false
+				return false
+			case unix.EAGAIN:
+				// The send buffer is full, and nothing was written. Wait for it to drain.
+				// Data written after waiting is not counted as carried in the SYN.
+				first = false
+				return false
+			}
+			if first && err == nil {
+				synBytes = n
+			}
+			return true
+		}); perr != nil {
+			return perr
+		}
+		if err != nil {
+			return os.NewSyscallError("writev", err)
+		}
+		if rest := buffersAfter(bufs, n); len(rest) > 0 {
+			return writeBuffers(c, rest)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	setConnTFOInfo(c, TFOInfo{Path: DialPathConnectOption, SYNBytes: synBytes})
+	return nil
 }
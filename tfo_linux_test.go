@@ -0,0 +1,109 @@
+package tfo
+
+import (
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// kernelVersionAtLeast returns whether the running kernel is at least major.minor.
+func kernelVersionAtLeast(t *testing.T, major, minor int) bool {
+	t.Helper()
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		t.Fatal(err)
+	}
+	release := unix.ByteSliceToString(uts.Release[:])
+	fields := strings.FieldsFunc(release, func(r rune) bool { return r < '0' || r > '9' })
+	if len(fields) < 2 {
+		t.Fatalf("cannot parse kernel release %q", release)
+	}
+	gotMajor, _ := strconv.Atoi(fields[0])
+	gotMinor, _ := strconv.Atoi(fields[1])
+	return gotMajor > major || gotMajor == major && gotMinor >= minor
+}
+
+// TestDialMultipathTCPSendto ensures that the sendto(MSG_FASTOPEN) path honors [net.Dialer.MultipathTCP].
+func TestDialMultipathTCPSendto(t *testing.T) {
+	if !mptcpAvailable() {
+		t.Skip("Multipath TCP is not supported by the kernel")
+	}
+
+	// Use cookieless TFO in a new namespace, so that the server always accepts the data in SYN.
+	// Otherwise, the kernel falls back to plain TCP when the data in SYN is not acknowledged.
+	ns := newTestNetNS(t)
+	setTestNetNSTFOSysctl(t, ns, tfoClientEnable|tfoServerEnable|tfoClientNoCookie|tfoServerCookieNotReqd)
+
+	lc := ListenConfig{NetNS: ns}
+	lc.SetMultipathTCP(true)
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		readUntilEOF(conn, hello, t)
+	}()
+
+	d := Dialer{NetNS: ns, Fallback: true, SupportCache: new(SupportCache)}
+	d.SetMultipathTCP(true)
+	d.SupportCache.storeDial(dialTFOSupportLinuxSendto)
+
+	var fallbacks []FallbackReason
+	ctx := WithDialTrace(t.Context(), &DialTrace{
+		Fallback: func(reason FallbackReason, _ error) {
+			fallbacks = append(fallbacks, reason)
+		},
+	})
+	c, err := d.DialContext(ctx, "tcp", ln.Addr().String(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	tc := c.(*net.TCPConn)
+
+	info, ok := ConnTFOInfo(c)
+	if !ok {
+		t.Fatal("ConnTFOInfo returned false")
+	}
+	t.Logf("info: %+v, fallbacks: %v", info, fallbacks)
+
+	if info.Path != DialPathSendmsg {
+		t.Errorf("info.Path = %v, want %v", info.Path, DialPathSendmsg)
+	}
+	if !info.SYNDataAcked {
+		t.Error("info.SYNDataAcked = false, want true")
+	}
+
+	// Multipath TCP supports data in SYN since Linux 6.2. Older kernels reject MSG_FASTOPEN
+	// before anything is sent, and the dialer falls back to plain TCP.
+	wantProto, wantMPTCP := unix.IPPROTO_MPTCP, true
+	if !kernelVersionAtLeast(t, 6, 2) {
+		wantProto, wantMPTCP = unix.IPPROTO_TCP, false
+	}
+	if proto := getsockoptInt(t, tc, unix.SOL_SOCKET, unix.SO_PROTOCOL); proto != wantProto {
+		t.Errorf("SO_PROTOCOL = %d, want %d", proto, wantProto)
+	}
+	if info.MultipathTCP != wantMPTCP {
+		t.Errorf("info.MultipathTCP = %v, want %v", info.MultipathTCP, wantMPTCP)
+	}
+	if slices.Contains(fallbacks, FallbackReasonMultipathTCP) == wantMPTCP {
+		t.Errorf("fallbacks = %v, want Multipath TCP fallback: %v", fallbacks, !wantMPTCP)
+	}
+
+	tc.CloseWrite()
+	<-done
+}
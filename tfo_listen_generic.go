@@ -15,6 +15,9 @@ func (lc *ListenConfig) listenTFO(ctx context.Context, network, address string)
 	ctrlFn := lc.Control
 	backlog := lc.Backlog
 	fallback := lc.Fallback
+	keys := lc.TFOKeys
+	noCookie := lc.NoCookie
+	supportCache := supportCacheOrDefault(lc.SupportCache)
 	llc := *lc
 	llc.Control = func(network, address string, c syscall.RawConn) (err error) {
 		if ctrlFn != nil {
@@ -33,9 +36,27 @@ func (lc *ListenConfig) listenTFO(ctx context.Context, network, address string)
 			if !fallback || !errors.Is(err, errors.ErrUnsupported) {
 				return os.NewSyscallError("setsockopt(TCP_FASTOPEN)", err)
 			}
-			runtimeListenNoTFO.Store(true)
+			supportCache.storeListenNoTFO()
+			return nil
 		}
-		return nil
+
+		if len(keys) > 0 {
+			if cerr := c.Control(func(fd uintptr) {
+				err = setTFOKeys(fd, keys)
+			}); cerr != nil {
+				return cerr
+			}
+			if err != nil {
+				return os.NewSyscallError("setsockopt(TCP_FASTOPEN_KEY)", err)
+			}
+		}
+
+		if cerr := c.Control(func(fd uintptr) {
+			err = setTFONoCookieIfEnabled(fd, noCookie, fallback, nil)
+		}); cerr != nil {
+			return cerr
+		}
+		return err
 	}
 	return llc.ListenConfig.Listen(ctx, network, address)
 }
@@ -9,6 +9,7 @@ import (
 	"net/netip"
 	"os"
 	"runtime"
+	"slices"
 	"sync"
 	"syscall"
 	"testing"
@@ -23,32 +24,21 @@ const (
 	mptcpDisabled
 )
 
-type runtimeFallbackHelperFunc func(*testing.T)
+// runtimeFallbackHelperFunc prepares a fresh [SupportCache] for a test case.
+type runtimeFallbackHelperFunc func(*SupportCache)
 
-func runtimeFallbackAsIs(t *testing.T) {}
+func runtimeFallbackAsIs(c *SupportCache) {}
 
-func runtimeFallbackSetListenNoTFO(t *testing.T) {
-	if runtimeListenNoTFO.CompareAndSwap(false, true) {
-		t.Cleanup(func() {
-			runtimeListenNoTFO.Store(false)
-		})
-	}
+func runtimeFallbackSetListenNoTFO(c *SupportCache) {
+	c.storeListenNoTFO()
 }
 
-func runtimeFallbackSetDialNoTFO(t *testing.T) {
-	if v := runtimeDialTFOSupport.v.Swap(uint32(dialTFOSupportNone)); v != uint32(dialTFOSupportNone) {
-		t.Cleanup(func() {
-			runtimeDialTFOSupport.v.Store(v)
-		})
-	}
+func runtimeFallbackSetDialNoTFO(c *SupportCache) {
+	c.storeDial(dialTFOSupportNone)
 }
 
-func runtimeFallbackSetDialLinuxSendto(t *testing.T) {
-	if v := runtimeDialTFOSupport.v.Swap(uint32(dialTFOSupportLinuxSendto)); v != uint32(dialTFOSupportLinuxSendto) {
-		t.Cleanup(func() {
-			runtimeDialTFOSupport.v.Store(v)
-		})
-	}
+func runtimeFallbackSetDialLinuxSendto(c *SupportCache) {
+	c.storeDial(dialTFOSupportLinuxSendto)
 }
 
 type listenConfigTestCase struct {
@@ -63,6 +53,14 @@ func (c listenConfigTestCase) shouldSkip() bool {
 	return comptimeListenNoTFO && !c.listenConfig.tfoDisabled()
 }
 
+// config returns the test case's [ListenConfig] with a fresh [SupportCache].
+func (c listenConfigTestCase) config() ListenConfig {
+	lc := c.listenConfig
+	lc.SupportCache = new(SupportCache)
+	c.setRuntimeFallback(lc.SupportCache)
+	return lc
+}
+
 func (c listenConfigTestCase) checkSkip(t *testing.T) {
 	if c.shouldSkip() {
 		t.Skip("not applicable to the current platform")
@@ -242,6 +240,14 @@ func (c dialerTestCase) shouldSkip() bool {
 	return false
 }
 
+// config returns the test case's [Dialer] with a fresh [SupportCache].
+func (c dialerTestCase) config() Dialer {
+	d := c.dialer
+	d.SupportCache = new(SupportCache)
+	c.setRuntimeFallback(d.SupportCache)
+	return d
+}
+
 func (c dialerTestCase) checkSkip(t *testing.T) {
 	if c.shouldSkip() {
 		t.Skip("not applicable to the current platform")
@@ -420,18 +426,14 @@ var dialerCases = []dialerTestCase{
 }
 
 type testCase struct {
-	name                     string
-	listenConfig             ListenConfig
-	dialer                   Dialer
-	setRuntimeFallbackListen runtimeFallbackHelperFunc
-	setRuntimeFallbackDial   runtimeFallbackHelperFunc
+	name         string
+	listenConfig listenConfigTestCase
+	dialer       dialerTestCase
 }
 
 func (c testCase) Run(t *testing.T, f func(*testing.T, ListenConfig, Dialer)) {
 	t.Run(c.name, func(t *testing.T) {
-		c.setRuntimeFallbackListen(t)
-		c.setRuntimeFallbackDial(t)
-		f(t, c.listenConfig, c.dialer)
+		f(t, c.listenConfig.config(), c.dialer.config())
 	})
 }
 
@@ -478,11 +480,9 @@ func init() {
 				continue
 			}
 			cases = append(cases, testCase{
-				name:                     lc.name + "/" + d.name,
-				listenConfig:             lc.listenConfig,
-				dialer:                   d.dialer,
-				setRuntimeFallbackListen: lc.setRuntimeFallback,
-				setRuntimeFallbackDial:   d.setRuntimeFallback,
+				name:         lc.name + "/" + d.name,
+				listenConfig: lc,
+				dialer:       d,
 			})
 		}
 	}
@@ -590,8 +590,7 @@ func TestListenCtrlFn(t *testing.T) {
 	for _, c := range listenConfigCases {
 		t.Run(c.name, func(t *testing.T) {
 			c.checkSkip(t)
-			c.setRuntimeFallback(t)
-			testListenCtrlFn(t, c.listenConfig)
+			testListenCtrlFn(t, c.config())
 		})
 	}
 }
@@ -605,15 +604,16 @@ func TestDialCtrlFn(t *testing.T) {
 	}
 	defer s.Close()
 
-	address := s.AddrPort().String()
+	raddr := s.AddrPort()
+	address := raddr.String()
 
 	for _, c := range dialerCases {
 		t.Run(c.name, func(t *testing.T) {
 			c.checkSkip(t)
-			c.setRuntimeFallback(t)
-			testDialCtrlFn(t, c.dialer, address)
-			testDialCtrlCtxFn(t, c.dialer, address)
-			testDialCtrlCtxFnSupersedesCtrlFn(t, c.dialer, address)
+			testDialCtrlFn(t, c.config(), address)
+			testDialTCPCtrlFn(t, c.config(), raddr)
+			testDialCtrlCtxFn(t, c.config(), address)
+			testDialCtrlCtxFnSupersedesCtrlFn(t, c.config(), address)
 		})
 	}
 }
@@ -622,9 +622,9 @@ func TestDialCtrlFn(t *testing.T) {
 func TestListenTFOStatus(t *testing.T) {
 	for _, c := range listenConfigCases {
 		t.Run(c.name, func(t *testing.T) {
-			c.setRuntimeFallback(t)
-			if got := c.listenConfig.TFO(); got != c.wantTFO {
-				t.Errorf("c.listenConfig.TFO() = %v, want %v", got, c.wantTFO)
+			lc := c.config()
+			if got := lc.TFO(); got != c.wantTFO {
+				t.Errorf("lc.TFO() = %v, want %v", got, c.wantTFO)
 			}
 		})
 	}
@@ -634,9 +634,9 @@ func TestListenTFOStatus(t *testing.T) {
 func TestDialTFOStatus(t *testing.T) {
 	for _, c := range dialerCases {
 		t.Run(c.name, func(t *testing.T) {
-			c.setRuntimeFallback(t)
-			if got := c.dialer.TFO(); got != c.wantTFO {
-				t.Errorf("c.dialer.TFO() = %v, want %v", got, c.wantTFO)
+			d := c.config()
+			if got := d.TFO(); got != c.wantTFO {
+				t.Errorf("d.TFO() = %v, want %v", got, c.wantTFO)
 			}
 		})
 	}
@@ -682,6 +682,21 @@ func TestSetDeadline(t *testing.T) {
 	}
 }
 
+// TestDialBuffers ensures that [Dialer.DialBuffers] sends all buffers in order,
+// and does not modify the buffers.
+func TestDialBuffers(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testDialBuffers)
+	}
+}
+
+// TestConnTFOInfo ensures that [ConnTFOInfo] reports the dial path taken.
+func TestConnTFOInfo(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testConnTFOInfo)
+	}
+}
+
 func testRawConnControl(t *testing.T, sc syscall.Conn) {
 	rawConn, err := sc.SyscallConn()
 	if err != nil {
@@ -745,6 +760,25 @@ func testDialCtrlFn(t *testing.T, d Dialer, address string) {
 	testRawConnControl(t, c.(syscall.Conn))
 }
 
+func testDialTCPCtrlFn(t *testing.T, d Dialer, raddr netip.AddrPort) {
+	var gotAddress string
+
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		gotAddress = address
+		return nil
+	}
+
+	c, err := d.DialTCP(t.Context(), "tcp", netip.AddrPort{}, raddr, hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if gotAddress != raddr.String() {
+		t.Errorf("Dialer ctrlFn got address %q, want %q", gotAddress, raddr)
+	}
+}
+
 func testDialCtrlCtxFn(t *testing.T, d Dialer, address string) {
 	type contextKey int
 
@@ -1091,3 +1125,95 @@ func testSetDeadline(t *testing.T, lc ListenConfig, d Dialer) {
 	tc.CloseWrite()
 	<-ctrlCh
 }
+
+func testConnTFOInfo(t *testing.T, lc ListenConfig, d Dialer) {
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lntcp := ln.(*net.TCPListener)
+	defer lntcp.Close()
+
+	ctrlCh := make(chan struct{})
+	go func() {
+		conn, err := lntcp.AcceptTCP()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		readUntilEOF(conn, hello, t)
+		close(ctrlCh)
+	}()
+
+	wantTFO := d.TFO()
+
+	c, err := d.DialContext(t.Context(), "tcp", ln.Addr().String(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := c.(*net.TCPConn)
+	defer tc.Close()
+
+	info, ok := ConnTFOInfo(tc)
+	if !ok {
+		t.Fatal("ConnTFOInfo returned false")
+	}
+	t.Logf("info: %+v", info)
+
+	if gotTFO := info.Path != DialPathPlain; gotTFO != wantTFO {
+		t.Errorf("info.Path = %v, want TFO %v", info.Path, wantTFO)
+	}
+	if info.SYNBytes < 0 || info.SYNBytes > len(hello) {
+		t.Errorf("info.SYNBytes = %d, want [0, %d]", info.SYNBytes, len(hello))
+	}
+
+	tc.CloseWrite()
+	<-ctrlCh
+
+	if _, ok := ConnTFOInfo(&net.TCPConn{}); ok {
+		t.Error("ConnTFOInfo returned true for unknown connection")
+	}
+}
+
+func testDialBuffers(t *testing.T, lc ListenConfig, d Dialer) {
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lntcp := ln.(*net.TCPListener)
+	defer lntcp.Close()
+
+	ctrlCh := make(chan struct{})
+	go func() {
+		conn, err := lntcp.AcceptTCP()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		readUntilEOF(conn, helloWorldSentence, t)
+		close(ctrlCh)
+	}()
+
+	bufs := net.Buffers{helloWorldSentence[:5], nil, helloWorldSentence[5:7], helloWorldSentence[7:]}
+	bufsCopy := slices.Clone(bufs)
+
+	c, err := d.DialBuffers(t.Context(), "tcp", ln.Addr().String(), bufs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := c.(*net.TCPConn)
+	defer tc.Close()
+
+	tc.CloseWrite()
+	<-ctrlCh
+
+	for i := range bufs {
+		if !slices.Equal(bufs[i], bufsCopy[i]) || cap(bufs[i]) != cap(bufsCopy[i]) {
+			t.Errorf("bufs[%d] = %v, want %v", i, bufs[i], bufsCopy[i])
+		}
+	}
+}
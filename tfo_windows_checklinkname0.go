@@ -33,7 +33,7 @@ func setUpdateConnectContext(fd windows.Handle) error {
 	return windows.Setsockopt(fd, windows.SOL_SOCKET, windows.SO_UPDATE_CONNECT_CONTEXT, nil, 0)
 }
 
-func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *net.TCPAddr, b []byte, ctrlCtxFn func(context.Context, string, string, syscall.RawConn) error) (*net.TCPConn, error) {
+func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *net.TCPAddr, bufs [][]byte, ctrlCtxFn func(context.Context, string, string, syscall.RawConn) error) (*net.TCPConn, error) {
 	family, ipv6only := favoriteDialAddrFamily(network, laddr, raddr)
 
 	lsa, err := windowsSockaddrFromTCPAddr(laddr, family)
@@ -62,22 +62,33 @@ func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *n
 
 	fd := newFD(handle, family, windows.SOCK_STREAM, network)
 
+	trace := ContextDialTrace(ctx)
+
 	if err = setIPv6Only(handle, family, ipv6only); err != nil {
+		trace.sockoptError("IPV6_V6ONLY", err)
 		fd.Close()
 		return nil, os.NewSyscallError("setsockopt(IPV6_V6ONLY)", err)
 	}
 
-	if err = setNoDelay(handle, 1); err != nil {
+	if err = setNoDelay(handle, boolint(!d.disableNoDelay)); err != nil {
+		trace.sockoptError("TCP_NODELAY", err)
 		fd.Close()
 		return nil, os.NewSyscallError("setsockopt(TCP_NODELAY)", err)
 	}
 
 	if err = setTFODialer(uintptr(handle)); err != nil {
+		trace.sockoptError("TCP_FASTOPEN", err)
 		if !d.Fallback || !errors.Is(err, errors.ErrUnsupported) {
 			fd.Close()
 			return nil, os.NewSyscallError("setsockopt(TCP_FASTOPEN)", err)
 		}
-		runtimeDialTFOSupport.storeNone()
+		d.supportCache().storeDial(dialTFOSupportNone)
+		reportFallback(ctx, FallbackReasonUnsupported, err)
+	}
+
+	if err = setTFONoCookieIfEnabled(uintptr(handle), d.NoCookie, d.Fallback, trace); err != nil {
+		fd.Close()
+		return nil, err
 	}
 
 	if ctrlCtxFn != nil {
@@ -97,8 +108,20 @@ func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *n
 		return nil, err
 	}
 
-	if err = connWriteFunc(ctx, fd, func(fd *netFD) error {
-		n, err := fd.pfd.ConnectEx(rsa, b)
+	var n int
+
+	if err = connWriteFunc(ctx, fd, func(fd *netFD) (err error) {
+		// ConnectEx only accepts a single buffer. Send the first non-empty one.
+		// The empty buffers before it do not affect the accounting in buffersAfter.
+		var b []byte
+		for _, buf := range bufs {
+			if len(buf) > 0 {
+				b = buf
+				break
+			}
+		}
+
+		n, err = fd.pfd.ConnectEx(rsa, b)
 		if err != nil {
 			return wrapSyscallError("connectex", err)
 		}
@@ -119,8 +142,8 @@ func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *n
 		}
 		fd.raddr = tcpAddrFromWindowsSockaddr(rsa)
 
-		if n < len(b) {
-			if _, err = fd.Write(b[n:]); err != nil {
+		for _, b := range buffersAfter(bufs, n) {
+			if _, err = fd.Write(b); err != nil {
 				return err
 			}
 		}
@@ -147,9 +170,15 @@ func (d *Dialer) dialSingle(ctx context.Context, network string, laddr, raddr *n
 		_ = tc.SetKeepAliveConfig(keepAliveCfg)
 	}
 
+	setConnTFOInfo(tc, TFOInfo{Path: DialPathConnectEx, SYNBytes: n})
 	return tc, nil
 }
 
+// isResetOrTimedOut returns whether err is a connection reset or a connection timeout.
+func isResetOrTimedOut(err error) bool {
+	return errors.Is(err, windows.WSAECONNRESET) || errors.Is(err, windows.WSAETIMEDOUT)
+}
+
 func windowsSockaddrFromTCPAddr(a *net.TCPAddr, family int) (windows.Sockaddr, error) {
 	if a == nil {
 		return nil, nil
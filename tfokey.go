@@ -0,0 +1,142 @@
+package tfo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// TFOKey is a TCP Fast Open cookie secret.
+// Servers sharing the same key issue and accept the same cookies.
+type TFOKey [16]byte
+
+// maxTFOKeys is the maximum number of keys a listener accepts: a primary key and a backup key.
+const maxTFOKeys = 2
+
+var errTFOKeyCount = errors.New("invalid number of TFO keys: want a primary key and an optional backup key")
+
+// SetTFOKeys sets the TFO cookie keys of the socket.
+// The first key is the primary key, which is used to issue new cookies.
+// The optional second key is the backup key, which is also accepted when validating cookies,
+// to allow graceful key rotation.
+//
+// This is only supported on Linux. Backup keys require Linux 5.4 or later.
+// Set the keys before listen(2) or on a listening socket.
+func SetTFOKeys(fd uintptr, keys ...TFOKey) error {
+	if len(keys) == 0 || len(keys) > maxTFOKeys {
+		return errTFOKeyCount
+	}
+	return setTFOKeys(fd, keys) // sockopt_linux.go, sockopt_tfokey_stub.go
+}
+
+// GetTFOKeys returns the TFO cookie keys of the socket, primary key first.
+// If the socket has no keys of its own, Linux returns the keys of the network namespace.
+//
+// This is only supported on Linux.
+func GetTFOKeys(fd uintptr) ([]TFOKey, error) {
+	return getTFOKeys(fd) // sockopt_linux.go, sockopt_tfokey_stub.go
+}
+
+// SetListenerTFOKeys is like [SetTFOKeys], but takes a listener, such as a [*net.TCPListener].
+func SetListenerTFOKeys(ln syscall.Conn, keys ...TFOKey) error {
+	if len(keys) == 0 || len(keys) > maxTFOKeys {
+		return errTFOKeyCount
+	}
+	rawConn, err := ln.SyscallConn()
+	if err != nil {
+		return err
+	}
+	if cerr := rawConn.Control(func(fd uintptr) {
+		err = setTFOKeys(fd, keys) // sockopt_linux.go, sockopt_tfokey_stub.go
+	}); cerr != nil {
+		return cerr
+	}
+	if err != nil && err != ErrPlatformUnsupported {
+		return os.NewSyscallError("setsockopt(TCP_FASTOPEN_KEY)", err)
+	}
+	return err
+}
+
+// defaultTFOKeyRotationInterval is the default key rotation interval of [TFOKeyRotator].
+const defaultTFOKeyRotationInterval = 24 * time.Hour
+
+// TFOKeyRotator derives TFO keys from a secret shared by a fleet of servers,
+// and rotates them on a fixed schedule.
+//
+// Time is divided into epochs of [TFOKeyRotator.Interval], aligned to the Unix epoch.
+// The key of each epoch is the first 16 bytes of HMAC-SHA256(Secret, epoch number).
+// The primary key is the key of the current epoch, and the backup key is the key of
+// the next epoch. Around a rotation, servers whose clocks lag behind still accept
+// cookies issued by servers that have already rotated. Cookies issued in the previous
+// epoch become invalid at rotation, and clients fall back to a regular handshake
+// to obtain new ones. All servers with the same secret and interval use the same keys.
+type TFOKeyRotator struct {
+	// Secret is the shared secret. It should be at least 32 bytes of random data.
+	Secret []byte
+
+	// Interval is the lifetime of a primary key.
+	// If zero or negative, the default of 24 hours is used.
+	Interval time.Duration
+}
+
+func (r *TFOKeyRotator) interval() time.Duration {
+	if r.Interval > 0 {
+		return r.Interval
+	}
+	return defaultTFOKeyRotationInterval
+}
+
+// epoch returns the epoch number of t.
+func (r *TFOKeyRotator) epoch(t time.Time) int64 {
+	return t.UnixNano() / int64(r.interval())
+}
+
+// key derives the key of the given epoch.
+func (r *TFOKeyRotator) key(epoch int64) (key TFOKey) {
+	mac := hmac.New(sha256.New, r.Secret)
+	_, _ = mac.Write(binary.BigEndian.AppendUint64(nil, uint64(epoch)))
+	copy(key[:], mac.Sum(nil))
+	return key
+}
+
+// KeysAt returns the primary key and the backup key at time t.
+// The result can be used as [ListenConfig.TFOKeys] or passed to [SetTFOKeys].
+func (r *TFOKeyRotator) KeysAt(t time.Time) []TFOKey {
+	epoch := r.epoch(t)
+	return []TFOKey{r.key(epoch), r.key(epoch + 1)}
+}
+
+// Keys returns the current primary key and backup key.
+func (r *TFOKeyRotator) Keys() []TFOKey {
+	return r.KeysAt(time.Now())
+}
+
+// Run sets the current keys on the listeners, and updates them at the start of each epoch,
+// until ctx is canceled or an error occurs.
+// It returns the context's error when canceled, or the first error setting the keys.
+func (r *TFOKeyRotator) Run(ctx context.Context, listeners ...syscall.Conn) error {
+	interval := r.interval()
+	for {
+		now := time.Now()
+		keys := r.KeysAt(now)
+		for _, ln := range listeners {
+			if err := SetListenerTFOKeys(ln, keys...); err != nil {
+				return err
+			}
+		}
+
+		next := time.Unix(0, (r.epoch(now)+1)*int64(interval))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package tfo
+
+import (
+	"errors"
+	"net"
+	"runtime"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTFOKeyRotator(t *testing.T) {
+	r := TFOKeyRotator{Secret: []byte("correct horse battery staple"), Interval: time.Hour}
+	now := time.Unix(1700000000, 0)
+
+	keys := r.KeysAt(now)
+	if len(keys) != 2 {
+		t.Fatalf("len(KeysAt()) = %d, want 2", len(keys))
+	}
+	if keys[0] == keys[1] {
+		t.Error("primary key equals backup key")
+	}
+	if got := r.KeysAt(now.Add(time.Second)); !slices.Equal(got, keys) {
+		t.Errorf("keys changed within the same epoch: %x, want %x", got, keys)
+	}
+
+	// The backup key becomes the primary key in the next epoch.
+	next := r.KeysAt(now.Add(time.Hour))
+	if next[0] != keys[1] {
+		t.Errorf("next primary key = %x, want previous backup key %x", next[0], keys[1])
+	}
+
+	other := TFOKeyRotator{Secret: []byte("incorrect horse battery staple"), Interval: time.Hour}
+	if other.KeysAt(now)[0] == keys[0] {
+		t.Error("different secrets derived the same key")
+	}
+}
+
+func getListenerTFOKeys(t *testing.T, ln syscall.Conn) []TFOKey {
+	t.Helper()
+	rawConn, err := ln.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []TFOKey
+	if cerr := rawConn.Control(func(fd uintptr) {
+		keys, err = GetTFOKeys(fd)
+	}); cerr != nil {
+		t.Fatal(cerr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestListenTFOKeys(t *testing.T) {
+	r := TFOKeyRotator{Secret: []byte("correct horse battery staple")}
+	keys := r.Keys()
+
+	lc := ListenConfig{TFOKeys: keys}
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if runtime.GOOS != "linux" {
+		if err == nil {
+			ln.Close()
+			t.Fatal("Listen with TFOKeys succeeded on an unsupported platform")
+		}
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("Listen error = %v, want %v", err, errors.ErrUnsupported)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lntcp := ln.(*net.TCPListener)
+
+	if got := getListenerTFOKeys(t, lntcp); !slices.Equal(got, keys) {
+		t.Errorf("GetTFOKeys() = %x, want %x", got, keys)
+	}
+
+	next := r.KeysAt(time.Now().Add(24 * time.Hour))
+	if err = SetListenerTFOKeys(lntcp, next...); err != nil {
+		t.Fatal(err)
+	}
+	if got := getListenerTFOKeys(t, lntcp); !slices.Equal(got, next) {
+		t.Errorf("GetTFOKeys() = %x, want %x", got, next)
+	}
+
+	if err = SetListenerTFOKeys(lntcp, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got := getListenerTFOKeys(t, lntcp); !slices.Equal(got, keys[:1]) {
+		t.Errorf("GetTFOKeys() = %x, want %x", got, keys[:1])
+	}
+
+	if err = SetListenerTFOKeys(lntcp, keys[0], keys[1], keys[0]); err != errTFOKeyCount {
+		t.Errorf("SetListenerTFOKeys with 3 keys error = %v, want %v", err, errTFOKeyCount)
+	}
+	lc.TFOKeys = append(keys, keys[0])
+	if ln, err := lc.Listen(t.Context(), "tcp", "[::1]:"); err == nil {
+		ln.Close()
+		t.Error("Listen succeeded with 3 keys")
+	}
+}
@@ -0,0 +1,108 @@
+package tfo
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+)
+
+// TLSDialer dials TLS connections with the ClientHello sent in the SYN whenever possible.
+//
+// Since [tls.Client] only writes the ClientHello after it has a connection, the TLS client
+// is given a [*LazyConn], which defers connecting until the ClientHello is written.
+// Use [ConnTFOInfo] or [ClientHelloInSYN] on the returned connection
+// to find out whether the ClientHello was carried in the SYN.
+//
+// It mirrors [tls.Dialer].
+type TLSDialer struct {
+	// NetDialer is the optional dialer to use for the TCP connections.
+	// A nil NetDialer is equivalent to the zero [Dialer].
+	NetDialer *Dialer
+
+	// Config is the TLS configuration to use for new connections.
+	// A nil configuration is equivalent to the zero configuration.
+	// See the documentation of [tls.Dialer.Config] for the defaults.
+	Config *tls.Config
+}
+
+// Dial connects to the given network address and initiates a TLS handshake,
+// returning the resulting TLS connection.
+//
+// The returned [net.Conn], if any, will always be of type [*tls.Conn].
+func (d *TLSDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the given network address and initiates a TLS handshake,
+// returning the resulting TLS connection.
+//
+// The provided context must be non-nil. If the context expires before the connection
+// is complete, an error is returned. Once successfully connected, any expiration
+// of the context will not affect the connection.
+//
+// The returned [net.Conn], if any, will always be of type [*tls.Conn].
+func (d *TLSDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	c, err := d.dial(ctx, network, address)
+	if err != nil {
+		return nil, err // return nil [net.Conn] instead of non-nil [net.Conn] with nil [*tls.Conn] pointer
+	}
+	return c, nil
+}
+
+// Modified from go1.26 src/crypto/tls/tls.go
+func (d *TLSDialer) dial(ctx context.Context, network, address string) (*tls.Conn, error) {
+	netDialer := d.NetDialer
+	if netDialer == nil {
+		netDialer = new(Dialer)
+	}
+
+	ctx, cancel := netDialer.dialCtx(ctx)
+	defer cancel()
+
+	rawConn, err := netDialer.DialLazy(ctx, network, address, CoalesceConfig{})
+	if err != nil {
+		return nil, err
+	}
+
+	config := d.Config
+	if config == nil {
+		config = &tls.Config{}
+	}
+	// If no ServerName is set, infer the ServerName
+	// from the hostname we're connecting to.
+	if config.ServerName == "" {
+		colonPos := strings.LastIndex(address, ":")
+		if colonPos == -1 {
+			colonPos = len(address)
+		}
+		hostname := address[:colonPos]
+
+		config = config.Clone()
+		config.ServerName = hostname
+	}
+
+	conn := tls.Client(rawConn, config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// DialTLSContext is like [TLSDialer.DialContext] with a zero [TLSDialer].
+//
+// It can be used as [net/http.Transport.DialTLSContext].
+func DialTLSContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var d TLSDialer
+	return d.DialContext(ctx, network, address)
+}
+
+// ClientHelloInSYN reports whether the ClientHello of c, a connection returned by [TLSDialer],
+// was at least partially carried in the SYN.
+//
+// On Linux, use [ConnTFOInfo] to also find out whether the server accepted the data in the SYN.
+func ClientHelloInSYN(c *tls.Conn) bool {
+	info, ok := ConnTFOInfo(c)
+	return ok && info.Path != DialPathPlain && info.SYNBytes > 0
+}
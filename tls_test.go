@@ -0,0 +1,143 @@
+package tfo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// newTestTLSConfigs returns a server config with a self-signed certificate for localhost,
+// and a client config that trusts it.
+func newTestTLSConfigs(t *testing.T) (serverConfig, clientConfig *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}, &tls.Config{
+		RootCAs:    pool,
+		ServerName: "localhost",
+	}
+}
+
+func testTLSDialer(t *testing.T, lc ListenConfig, d Dialer) {
+	serverConfig, clientConfig := newTestTLSConfigs(t)
+
+	ln, err := lc.Listen(t.Context(), "tcp", "[::1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		tln := tls.NewListener(ln, serverConfig)
+		for {
+			conn, err := tln.Accept()
+			if err != nil {
+				return
+			}
+			readUntilEOF(conn, hello, t)
+			write(conn, world, t)
+			conn.Close()
+		}
+	}()
+
+	td := TLSDialer{NetDialer: &d, Config: clientConfig}
+	wantSYNData := synDataExpected(t, lc, d)
+
+	// The first connection may only obtain a TFO cookie.
+	for i := range 2 {
+		c, err := td.DialContext(t.Context(), "tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc := c.(*tls.Conn)
+		info, _ := ConnTFOInfo(tc)
+		t.Logf("info: %+v, ClientHelloInSYN: %v", info, ClientHelloInSYN(tc))
+
+		if i == 1 && wantSYNData {
+			if !ClientHelloInSYN(tc) {
+				t.Error("ClientHelloInSYN = false, want true")
+			}
+			if info.SYNBytes <= 0 {
+				t.Errorf("info.SYNBytes = %d, want > 0", info.SYNBytes)
+			}
+		}
+
+		write(tc, hello, t)
+		if err = tc.CloseWrite(); err != nil {
+			t.Fatal(err)
+		}
+		readUntilEOF(tc, world, t)
+		tc.Close()
+	}
+}
+
+// synDataExpected returns whether connections dialed by d to listeners created by lc
+// carry data in the SYN once the client has a cookie.
+// This is only determined on Linux, where the system configuration can be probed.
+func synDataExpected(t *testing.T, lc ListenConfig, d Dialer) bool {
+	if runtime.GOOS != "linux" || !d.TFO() || !lc.TFO() {
+		return false
+	}
+	r, err := Probe(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.ClientEnabled && r.ServerEnabled
+}
+
+func TestTLSDialer(t *testing.T) {
+	for _, c := range cases {
+		c.Run(t, testTLSDialer)
+	}
+}
+
+func TestTLSDialerHandshakeError(t *testing.T) {
+	serverConfig, _ := newTestTLSConfigs(t)
+
+	ln, err := tls.Listen("tcp", "[::1]:", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+
+	// The certificate is not trusted by the default config.
+	var td TLSDialer
+	if c, err := td.DialContext(t.Context(), "tcp", ln.Addr().String()); err == nil {
+		c.Close()
+		t.Error("DialContext succeeded with an untrusted certificate")
+	}
+}
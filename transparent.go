@@ -0,0 +1,38 @@
+package tfo
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+)
+
+var errNotTCPConn = errors.New("not a TCP connection")
+
+// OriginalDestination returns the original destination address of c, an accepted connection
+// that was intercepted by a transparent proxy setup, or any connection that wraps one
+// and has a NetConn method.
+//
+// On Linux, the destination is read with SO_ORIGINAL_DST (IP6T_SO_ORIGINAL_DST on IPv6),
+// which covers connections redirected by NAT, such as the iptables REDIRECT target.
+// When conntrack has no record of c, such as for connections redirected by TPROXY to a listener
+// with [SocketOptions.Transparent] set, the local address of c is returned, which is the
+// original destination in that case. On other platforms, the local address of c is returned.
+func OriginalDestination(c net.Conn) (netip.AddrPort, error) {
+	for {
+		if tc, isTCPConn := c.(*net.TCPConn); isTCPConn {
+			return originalDestination(tc) // transparent_linux.go, transparent_stub.go
+		}
+
+		nc, isWrapper := c.(interface{ NetConn() net.Conn })
+		if !isWrapper {
+			return netip.AddrPort{}, &net.OpError{Op: "getsockopt", Net: c.LocalAddr().Network(), Source: c.RemoteAddr(), Addr: c.LocalAddr(), Err: errNotTCPConn}
+		}
+		c = nc.NetConn()
+	}
+}
+
+// unmappedLocalAddrPort returns the local address of c, with any IPv4-mapped IPv6 address unmapped.
+func unmappedLocalAddrPort(c *net.TCPConn) netip.AddrPort {
+	addr := c.LocalAddr().(*net.TCPAddr).AddrPort()
+	return netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+}
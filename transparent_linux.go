@@ -0,0 +1,60 @@
+package tfo
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ip6tSOOriginalDst is IP6T_SO_ORIGINAL_DST, SO_ORIGINAL_DST for IPv6, from linux/netfilter_ipv6/ip6_tables.h.
+const ip6tSOOriginalDst = 80
+
+func originalDestination(c *net.TCPConn) (netip.AddrPort, error) {
+	laddr := unmappedLocalAddrPort(c)
+
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	var (
+		addr netip.AddrPort
+		name string
+	)
+	if cerr := rawConn.Control(func(fd uintptr) {
+		if laddr.Addr().Is4() {
+			name = "getsockopt(SO_ORIGINAL_DST)"
+			// The kernel writes a struct sockaddr_in, which fits in struct ipv6_mreq.
+			var mreq *unix.IPv6Mreq
+			if mreq, err = unix.GetsockoptIPv6Mreq(int(fd), unix.SOL_IP, unix.SO_ORIGINAL_DST); err == nil {
+				b := mreq.Multiaddr[:]
+				addr = netip.AddrPortFrom(netip.AddrFrom4([4]byte(b[4:8])), binary.BigEndian.Uint16(b[2:4]))
+			}
+			return
+		}
+		name = "getsockopt(IP6T_SO_ORIGINAL_DST)"
+		// The kernel writes a struct sockaddr_in6, which fits in struct ip6_mtuinfo.
+		var info *unix.IPv6MTUInfo
+		if info, err = unix.GetsockoptIPv6MTUInfo(int(fd), unix.SOL_IPV6, ip6tSOOriginalDst); err == nil {
+			port := (*[2]byte)(unsafe.Pointer(&info.Addr.Port))
+			addr = netip.AddrPortFrom(netip.AddrFrom16(info.Addr.Addr).Unmap(), binary.BigEndian.Uint16(port[:]))
+		}
+	}); cerr != nil {
+		return netip.AddrPort{}, cerr
+	}
+
+	switch err {
+	case nil:
+		return addr, nil
+	case unix.ENOENT, unix.ENOPROTOOPT:
+		// ENOENT: conntrack has no record of the connection, or it was not redirected by NAT.
+		// ENOPROTOOPT: conntrack is not loaded.
+		return laddr, nil
+	default:
+		return netip.AddrPort{}, os.NewSyscallError(name, err)
+	}
+}
@@ -0,0 +1,98 @@
+package tfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestOriginalDestination(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:", "[::1]:"} {
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		sc, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sc.Close()
+
+		// Without NAT, the original destination is the local address.
+		addr, err := OriginalDestination(sc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := ln.Addr().(*net.TCPAddr).AddrPort(); addr != want {
+			t.Errorf("OriginalDestination() = %v, want %v", addr, want)
+		}
+	}
+}
+
+// TestTransparent ensures that a transparent dialer can bind a non-local source address
+// on the sendto(MSG_FASTOPEN) path, and that a transparent listener has TFO enabled.
+func TestTransparent(t *testing.T) {
+	ns := newTestNetNS(t)
+	setTestNetNSTFOSysctl(t, ns, tfoClientEnable|tfoServerEnable)
+
+	lc := ListenConfig{
+		SocketOptions: SocketOptions{Transparent: true},
+		NetNS:         ns,
+	}
+	ln, err := lc.ListenTCP(t.Context(), "tcp4", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if got := getsockoptInt(t, ln, unix.IPPROTO_IP, unix.IP_TRANSPARENT); got != 1 {
+		t.Errorf("IP_TRANSPARENT = %d, want 1", got)
+	}
+	if got := getsockoptInt(t, ln, unix.IPPROTO_TCP, unix.TCP_FASTOPEN); got == 0 {
+		t.Error("TCP_FASTOPEN is not enabled on the transparent listener")
+	}
+
+	sendto := new(SupportCache)
+	sendto.storeDial(dialTFOSupportLinuxSendto)
+
+	// 198.18.0.1 is reserved for benchmarking, and is not a local address in the namespace.
+	// Replies to it cannot be routed, so the handshake never completes.
+	d := Dialer{
+		Dialer:       net.Dialer{LocalAddr: &net.TCPAddr{IP: net.IPv4(198, 18, 0, 1)}},
+		Fallback:     true,
+		SupportCache: sendto,
+		NetNS:        ns,
+	}
+	dial := func() error {
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+		c, err := d.DialContext(ctx, "tcp4", ln.Addr().String(), hello)
+		if err == nil {
+			c.Close()
+		}
+		return err
+	}
+
+	if err = dial(); !errors.Is(err, unix.EADDRNOTAVAIL) {
+		t.Errorf("non-transparent dial error = %v, want %v", err, unix.EADDRNOTAVAIL)
+	}
+
+	d.SocketOptions.Transparent = true
+	err = dial()
+	t.Logf("transparent dial error: %v", err)
+	if errors.Is(err, unix.EADDRNOTAVAIL) {
+		t.Errorf("transparent dial error = %v, want bind to succeed", err)
+	}
+}
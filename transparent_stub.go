@@ -0,0 +1,12 @@
+//go:build !linux
+
+package tfo
+
+import (
+	"net"
+	"net/netip"
+)
+
+func originalDestination(c *net.TCPConn) (netip.AddrPort, error) {
+	return unmappedLocalAddrPort(c), nil
+}